@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/logging"
+)
+
+// Config is the auth-service configuration, loaded by config.Load
+type Config struct {
+	GRPCPort  int    `yaml:"grpc_port" env:"GRPC_PORT" default:"50051"`
+	HTTPPort  int    `yaml:"http_port" env:"HTTP_PORT" default:"8080"`
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" required:"true" secret:"true"`
+
+	// ServiceToken is the bearer token other services present to call the
+	// internal RPCs, which users may not call
+	ServiceToken string `yaml:"service_token" env:"SERVICE_TOKEN" required:"true" secret:"true"`
+
+	Password  PasswordConfig   `yaml:"password"`
+	Messages  MessagesConfig   `yaml:"messages"`
+	TLS       config.TLS       `yaml:"tls"`
+	CORS      config.CORS      `yaml:"cors"`
+	Database  config.Database  `yaml:"database"`
+	Kafka     KafkaConfig      `yaml:"kafka"`
+	Log       config.Log       `yaml:"log"`
+	Lifecycle config.Lifecycle `yaml:"lifecycle"`
+}
+
+// PasswordConfig configures the signup password policy
+type PasswordConfig struct {
+	MinLength      int    `yaml:"min_length" env:"PASSWORD_MIN_LENGTH" default:"8"`
+	MinCharClasses int    `yaml:"min_char_classes" env:"PASSWORD_MIN_CHAR_CLASSES" default:"2"`
+	BreachedList   string `yaml:"breached_list" env:"PASSWORD_BREACHED_LIST"`
+}
+
+// MessagesConfig limits how sent messages may be changed
+type MessagesConfig struct {
+	EditWindow      time.Duration `yaml:"edit_window" env:"MESSAGE_EDIT_WINDOW" default:"15m"`
+	MaxContentBytes int           `yaml:"max_content_bytes" env:"MESSAGE_MAX_CONTENT_BYTES" default:"8192"`
+}
+
+// Validate checks that the window and size are positive
+func (m *MessagesConfig) Validate() []string {
+	var problems []string
+	if m.EditWindow <= 0 {
+		problems = append(problems, "MESSAGE_EDIT_WINDOW must be positive")
+	}
+	if m.MaxContentBytes < 1 {
+		problems = append(problems, "MESSAGE_MAX_CONTENT_BYTES must be at least 1")
+	}
+	return problems
+}
+
+// KafkaConfig selects the topic edit and delete events are published to,
+// which must be the one ws-service publishes messages to and delivers from
+type KafkaConfig struct {
+	config.Kafka  `yaml:",inline"`
+	MessagesTopic string        `yaml:"messages_topic" env:"KAFKA_MESSAGES_TOPIC" default:"messages"`
+	WriteTimeout  time.Duration `yaml:"write_timeout" env:"KAFKA_WRITE_TIMEOUT" default:"5s"`
+
+	MessagesProducer    config.Producer `yaml:"messages_producer" env_prefix:"KAFKA_MESSAGES_"`
+	MessagesTopicConfig config.Topic    `yaml:"messages_topic_config" env_prefix:"KAFKA_MESSAGES_"`
+}
+
+// Validate checks the ports, password policy bounds and write timeout
+func (c *Config) Validate() []string {
+	problems := config.ValidPort("GRPC_PORT", c.GRPCPort)
+	problems = append(problems, config.ValidPort("HTTP_PORT", c.HTTPPort)...)
+	if c.GRPCPort == c.HTTPPort {
+		problems = append(problems, "GRPC_PORT and HTTP_PORT must differ")
+	}
+	if c.Password.MinLength < 1 {
+		problems = append(problems, "PASSWORD_MIN_LENGTH must be at least 1")
+	}
+	if c.Password.MinCharClasses < 1 || c.Password.MinCharClasses > 4 {
+		problems = append(problems, "PASSWORD_MIN_CHAR_CLASSES must be between 1 and 4")
+	}
+	if c.Kafka.WriteTimeout <= 0 {
+		problems = append(problems, "KAFKA_WRITE_TIMEOUT must be positive")
+	}
+	return problems
+}
+
+// loadConfig loads the configuration, exiting with every problem listed if it
+// is invalid, then sets up logging and prints the effective values
+func loadConfig() Config {
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Init("auth-service", cfg.Log.Level, cfg.Log.Format)
+	slog.Info("Effective configuration", "config", config.Redact(&cfg))
+	return cfg
+}
+
+// migrateConfig is the part of Config the migrate subcommand uses, so that
+// schema changes do not need the settings only the running service needs
+type migrateConfig struct {
+	Database config.Database `yaml:"database"`
+	Log      config.Log      `yaml:"log"`
+}
+
+// loadMigrateConfig loads only the database and log settings, exiting like
+// loadConfig if they are invalid
+func loadMigrateConfig() config.Database {
+	var cfg migrateConfig
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Init("auth-service", cfg.Log.Level, cfg.Log.Format)
+	slog.Info("Effective configuration", "config", config.Redact(&cfg))
+	return cfg.Database
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/RishangS/auth-service/validation"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AddContact adds a user to the caller's contacts
+func (h *AuthHandler) AddContact(ctx context.Context, req *auth.ContactRequest) (*auth.Contact, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	contact, err := h.contacts.AddContact(ctx, userID, req.Username)
+	if err != nil {
+		return nil, contactStatus(err)
+	}
+	return contactProto(*contact), nil
+}
+
+// RemoveContact removes a user from the caller's contacts
+func (h *AuthHandler) RemoveContact(ctx context.Context, req *auth.ContactRequest) (*emptypb.Empty, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.contacts.RemoveContact(ctx, userID, req.Username); err != nil {
+		return nil, contactStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListContacts returns the caller's contacts
+func (h *AuthHandler) ListContacts(ctx context.Context, req *auth.ListContactsRequest) (*auth.ListContactsResponse, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	contacts, err := h.contacts.ListContacts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return listProto(contacts), nil
+}
+
+// BlockUser adds a user to the caller's block list
+func (h *AuthHandler) BlockUser(ctx context.Context, req *auth.ContactRequest) (*auth.Contact, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := h.contacts.BlockUser(ctx, userID, req.Username)
+	if err != nil {
+		return nil, contactStatus(err)
+	}
+	return contactProto(*blocked), nil
+}
+
+// UnblockUser removes a user from the caller's block list
+func (h *AuthHandler) UnblockUser(ctx context.Context, req *auth.ContactRequest) (*emptypb.Empty, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.contacts.UnblockUser(ctx, userID, req.Username); err != nil {
+		return nil, contactStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ListBlockedUsers returns the caller's block list
+func (h *AuthHandler) ListBlockedUsers(ctx context.Context, req *auth.ListContactsRequest) (*auth.ListContactsResponse, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	blocked, err := h.contacts.ListBlocked(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return listProto(blocked), nil
+}
+
+// SetContactsOnly restricts the caller's incoming messages to contacts
+func (h *AuthHandler) SetContactsOnly(ctx context.Context, req *auth.SetContactsOnlyRequest) (*emptypb.Empty, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.contacts.SetContactsOnly(ctx, userID, req.ContactsOnly); err != nil {
+		return nil, contactStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// CheckMessaging reports whether sender may message recipient, returning
+// NotFound if either user does not exist. Only services may call it.
+func (h *AuthHandler) CheckMessaging(ctx context.Context, req *auth.CheckMessagingRequest) (*auth.CheckMessagingResponse, error) {
+	if err := h.authenticateService(ctx, req); err != nil {
+		return nil, err
+	}
+
+	decision := auth.MessagingDecision_MESSAGING_ALLOWED
+	switch err := h.contacts.CheckMessaging(ctx, req.Sender, req.Recipient); {
+	case errors.Is(err, store.ErrRecipientBlocked):
+		decision = auth.MessagingDecision_MESSAGING_RECIPIENT_BLOCKED
+	case errors.Is(err, store.ErrMessagingNotAllowed):
+		decision = auth.MessagingDecision_MESSAGING_NOT_ACCEPTED
+	case err != nil:
+		return nil, contactStatus(err)
+	}
+	return &auth.CheckMessagingResponse{Decision: decision}, nil
+}
+
+// UserIDMetadata names the metadata in which a service calling with the
+// service token names the user it acts for
+const UserIDMetadata = "x-user-id"
+
+// authenticate validates req and returns the ID of the user whose access
+// token is in the authorization metadata, which the gateway fills from the
+// Authorization header. A service may instead send the service token and
+// the user's ID in UserIDMetadata.
+func (h *AuthHandler) authenticate(ctx context.Context, req proto.Message) (int, error) {
+	if err := validation.Validate(req); err != nil {
+		return 0, err
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.serviceToken)) == 1 {
+		return serviceUserID(ctx)
+	}
+	claims, err := h.authClient.ValidateJWT(token)
+	if err != nil {
+		return 0, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if isRefresh, _ := claims["is_refresh"].(bool); !ok || isRefresh {
+		return 0, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return int(userID), nil
+}
+
+// authenticateService validates req and checks that the caller presented the
+// service token, so that only other services reach internal RPCs
+func (h *AuthHandler) authenticateService(ctx context.Context, req proto.Message) error {
+	if err := validation.Validate(req); err != nil {
+		return err
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.serviceToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "only services may call this method")
+	}
+	return nil
+}
+
+// serviceUserID returns the user ID a service sent in UserIDMetadata
+func serviceUserID(ctx context.Context) (int, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(UserIDMetadata)
+	if len(values) == 0 {
+		return 0, status.Error(codes.Unauthenticated, UserIDMetadata+" is required with the service token")
+	}
+	userID, err := strconv.Atoi(values[0])
+	if err != nil || userID < 1 {
+		return 0, status.Error(codes.Unauthenticated, "invalid "+UserIDMetadata)
+	}
+	return userID, nil
+}
+
+// bearerToken returns the bearer token in the authorization metadata
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Error(codes.Unauthenticated, "authorization bearer token is required")
+	}
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+	return token, nil
+}
+
+// contactStatus maps contact store errors to gRPC status errors
+func contactStatus(err error) error {
+	switch {
+	case errors.Is(err, store.ErrUserNotFound), errors.Is(err, store.ErrContactNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrSelfContact):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
+
+func contactProto(c store.Contact) *auth.Contact {
+	return &auth.Contact{
+		UserId:    int64(c.UserID),
+		Username:  c.Username,
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
+
+func listProto(contacts []store.Contact) *auth.ListContactsResponse {
+	resp := &auth.ListContactsResponse{Contacts: make([]*auth.Contact, len(contacts))}
+	for i, c := range contacts {
+		resp.Contacts[i] = contactProto(c)
+	}
+	return resp
+}
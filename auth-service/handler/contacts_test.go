@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/RishangS/auth-service/utils"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testServiceToken = "service-token"
+
+// newTestHandler returns a handler over memory stores holding alice, bob and
+// carol, with their user IDs
+func newTestHandler(t *testing.T) (*AuthHandler, map[string]int) {
+	t.Helper()
+	users := store.NewMemoryUserStore()
+	ids := make(map[string]int)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := users.CreateUser(context.Background(), name, "Correct-horse7", name+"@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = user.ID
+	}
+	messages := Messages{Store: store.NewMemoryMessageStore(users)}
+	h := NewAuthHandler(users, store.NewMemoryContactStore(users), messages, utils.NewAuthClient("secret"), nil, testServiceToken)
+	return h, ids
+}
+
+// withBearer returns an incoming context carrying token and the other
+// metadata pairs given
+func withBearer(token string, pairs ...string) context.Context {
+	md := metadata.Pairs(append([]string{"authorization", "Bearer " + token}, pairs...)...)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// asUser returns an incoming context authenticated as userID with a JWT
+func asUser(t *testing.T, h *AuthHandler, userID int) context.Context {
+	t.Helper()
+	token, err := h.authClient.GenerateJWT(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withBearer(token)
+}
+
+// TestCheckMessagingRules checks blocks in both directions, contacts-only
+// recipients and unknown users
+func TestCheckMessagingRules(t *testing.T) {
+	h, ids := newTestHandler(t)
+	service := withBearer(testServiceToken)
+
+	check := func(sender, recipient string, want auth.MessagingDecision) {
+		t.Helper()
+		resp, err := h.CheckMessaging(service, &auth.CheckMessagingRequest{Sender: sender, Recipient: recipient})
+		if err != nil {
+			t.Fatalf("%s to %s: %v", sender, recipient, err)
+		}
+		if resp.Decision != want {
+			t.Errorf("%s to %s: got %s, want %s", sender, recipient, resp.Decision, want)
+		}
+	}
+	call := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check("alice", "bob", auth.MessagingDecision_MESSAGING_ALLOWED)
+
+	// A block stops messages both ways, telling each side apart
+	_, err := h.BlockUser(asUser(t, h, ids["bob"]), &auth.ContactRequest{Username: "alice"})
+	call(err)
+	check("alice", "bob", auth.MessagingDecision_MESSAGING_NOT_ACCEPTED)
+	check("bob", "alice", auth.MessagingDecision_MESSAGING_RECIPIENT_BLOCKED)
+	_, err = h.UnblockUser(asUser(t, h, ids["bob"]), &auth.ContactRequest{Username: "alice"})
+	call(err)
+	check("alice", "bob", auth.MessagingDecision_MESSAGING_ALLOWED)
+
+	// A contacts-only user hears only from their contacts
+	carol := asUser(t, h, ids["carol"])
+	_, err = h.SetContactsOnly(carol, &auth.SetContactsOnlyRequest{ContactsOnly: true})
+	call(err)
+	check("alice", "carol", auth.MessagingDecision_MESSAGING_NOT_ACCEPTED)
+	check("carol", "alice", auth.MessagingDecision_MESSAGING_ALLOWED)
+	_, err = h.AddContact(carol, &auth.ContactRequest{Username: "alice"})
+	call(err)
+	check("alice", "carol", auth.MessagingDecision_MESSAGING_ALLOWED)
+	check("bob", "carol", auth.MessagingDecision_MESSAGING_NOT_ACCEPTED)
+
+	_, err = h.CheckMessaging(service, &auth.CheckMessagingRequest{Sender: "alice", Recipient: "dave"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown recipient: got %v, want NotFound", err)
+	}
+}
+
+// TestContactRules checks the errors for adding oneself or an unknown user
+func TestContactRules(t *testing.T) {
+	h, ids := newTestHandler(t)
+	alice := asUser(t, h, ids["alice"])
+
+	for _, tc := range []struct {
+		username string
+		want     codes.Code
+	}{
+		{username: "alice", want: codes.InvalidArgument},
+		{username: "dave", want: codes.NotFound},
+	} {
+		if _, err := h.AddContact(alice, &auth.ContactRequest{Username: tc.username}); status.Code(err) != tc.want {
+			t.Errorf("add %s: got %v, want %s", tc.username, err, tc.want)
+		}
+		if _, err := h.BlockUser(alice, &auth.ContactRequest{Username: tc.username}); status.Code(err) != tc.want {
+			t.Errorf("block %s: got %v, want %s", tc.username, err, tc.want)
+		}
+	}
+}
+
+// TestServiceAuthentication checks that CheckMessaging is for services only
+// and that a service may act for a user named in UserIDMetadata
+func TestServiceAuthentication(t *testing.T) {
+	h, ids := newTestHandler(t)
+	req := &auth.CheckMessagingRequest{Sender: "alice", Recipient: "bob"}
+
+	if _, err := h.CheckMessaging(asUser(t, h, ids["alice"]), req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("user token: got %v, want PermissionDenied", err)
+	}
+	if _, err := h.CheckMessaging(withBearer("wrong"), req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("wrong token: got %v, want PermissionDenied", err)
+	}
+
+	alice := withBearer(testServiceToken, UserIDMetadata, strconv.Itoa(ids["alice"]))
+	if _, err := h.AddContact(alice, &auth.ContactRequest{Username: "bob"}); err != nil {
+		t.Fatalf("service acting for alice: %v", err)
+	}
+	resp, err := h.ListContacts(asUser(t, h, ids["alice"]), &auth.ListContactsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Contacts) != 1 || resp.Contacts[0].Username != "bob" {
+		t.Errorf("alice's contacts are %v, want bob", resp.Contacts)
+	}
+
+	for _, ctx := range []context.Context{
+		withBearer(testServiceToken),
+		withBearer(testServiceToken, UserIDMetadata, "alice"),
+	} {
+		if _, err := h.ListContacts(ctx, &auth.ListContactsRequest{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("service token without a valid user ID: got %v, want Unauthenticated", err)
+		}
+	}
+}
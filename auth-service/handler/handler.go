@@ -1,147 +1,187 @@
-package handler
-
-import (
-	"context"
-	"errors"
-	"log"
-
-	auth "github.com/RishangS/auth-service/gen/proto"
-	"github.com/RishangS/auth-service/utils"
-)
-
-type AuthHandler struct {
-	auth.UnimplementedAuthServiceServer
-	userRepo   *utils.UserRepository
-	authClient *utils.AuthClient
-}
-
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{
-		userRepo:   utils.NewDBService(),
-		authClient: utils.NewAuthClient(),
-	}
-}
-
-// Signup handles user registration
-func (h *AuthHandler) Signup(ctx context.Context, req *auth.SignupRequest) (*auth.SignupResponse, error) {
-	if req.Username == "" || req.Password == "" || req.Email == "" {
-		return nil, errors.New("username, password and email are required")
-	}
-
-	user, err := h.userRepo.CreateUser(ctx, req.Username, req.Password, req.Email)
-	if err != nil {
-		return nil, err
-	}
-
-	return &auth.SignupResponse{
-		UserId:   int64(user.ID),
-		Username: user.Username,
-		Email:    user.Email,
-	}, nil
-}
-
-// Login handles user authentication and returns JWT tokens
-func (h *AuthHandler) Login(ctx context.Context, req *auth.LoginRequest) (*auth.LoginResponse, error) {
-	if req.Username == "" || req.Password == "" {
-		return nil, errors.New("username and password are required")
-	}
-
-	// Authenticate user
-	user, err := h.userRepo.AuthenticateUser(ctx, req.Username, req.Password)
-	if err != nil {
-		return nil, err
-	}
-
-	// Generate access token
-	accessToken, err := h.authClient.GenerateJWT(user.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Generate refresh token
-	refreshToken, err := h.authClient.GenerateRefreshToken(user.ID)
-	if err != nil {
-		return nil, err
-	}
-
-	return &auth.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
-}
-
-// VerifyToken validates the JWT token and returns user information
-func (h *AuthHandler) VerifyToken(ctx context.Context, req *auth.VerifyRequest) (*auth.VerifyResponse, error) {
-	log.Println("VerifyToken")
-	if req.Token == "" {
-		return nil, errors.New("token is required")
-	}
-
-	claims, err := h.authClient.ValidateJWT(req.Token)
-	if err != nil {
-		return &auth.VerifyResponse{
-			Valid: false,
-		}, nil
-	}
-
-	// Get user ID from claims
-	userID, ok := claims["user_id"].(float64)
-	if !ok {
-		return &auth.VerifyResponse{
-			Valid: false,
-		}, nil
-	}
-
-	// Get user details from database
-	user, err := h.userRepo.GetUserByID(ctx, int(userID))
-	if err != nil {
-		return &auth.VerifyResponse{
-			Valid: false,
-		}, nil
-	}
-
-	return &auth.VerifyResponse{
-		Valid:    true,
-		Username: user.Username,
-	}, nil
-}
-
-// RefreshToken generates a new access token using a valid refresh token
-func (h *AuthHandler) RefreshToken(ctx context.Context, req *auth.RefreshRequest) (*auth.LoginResponse, error) {
-	if req.RefreshToken == "" {
-		return nil, errors.New("refresh token is required")
-	}
-
-	// Generate new access token using refresh token
-	newAccessToken, err := h.authClient.RefreshAccessToken(req.RefreshToken)
-	if err != nil {
-		return nil, err
-	}
-
-	// Generate new refresh token
-	claims, err := h.authClient.ValidateJWT(req.RefreshToken)
-	if err != nil {
-		return nil, err
-	}
-
-	userID, ok := claims["user_id"].(float64)
-	if !ok {
-		return nil, errors.New("invalid refresh token claims")
-	}
-
-	newRefreshToken, err := h.authClient.GenerateRefreshToken(int(userID))
-	if err != nil {
-		return nil, err
-	}
-
-	// Revoke old refresh token
-	err = h.authClient.RevokeRefreshToken(req.RefreshToken)
-	if err != nil {
-		log.Printf("Warning: Failed to revoke old refresh token: %v", err)
-	}
-
-	return &auth.LoginResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-	}, nil
-}
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/RishangS/auth-service/utils"
+	"github.com/RishangS/auth-service/validation"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AuthHandler struct {
+	auth.UnimplementedAuthServiceServer
+	userRepo       store.UserStore
+	contacts       store.ContactStore
+	messages       Messages
+	authClient     *utils.AuthClient
+	passwordPolicy *utils.PasswordPolicy
+	serviceToken   string
+}
+
+func NewAuthHandler(users store.UserStore, contacts store.ContactStore, messages Messages, authClient *utils.AuthClient, passwordPolicy *utils.PasswordPolicy, serviceToken string) *AuthHandler {
+	return &AuthHandler{
+		userRepo:       users,
+		contacts:       contacts,
+		messages:       messages,
+		authClient:     authClient,
+		passwordPolicy: passwordPolicy,
+		serviceToken:   serviceToken,
+	}
+}
+
+// Signup handles user registration
+func (h *AuthHandler) Signup(ctx context.Context, req *auth.SignupRequest) (*auth.SignupResponse, error) {
+	violations := validation.Check(req)
+	if req.Password != "" {
+		for _, reason := range h.passwordPolicy.Check(req.Password, req.Username) {
+			violations = append(violations, validation.FieldViolation{Field: "password", Description: reason})
+		}
+	}
+	if err := validation.NewError(violations); err != nil {
+		return nil, err
+	}
+
+	user, err := h.userRepo.CreateUser(ctx, req.Username, req.Password, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.SignupResponse{
+		UserId:   int64(user.ID),
+		Username: user.Username,
+		Email:    user.Email,
+	}, nil
+}
+
+// Login handles user authentication and returns JWT tokens
+func (h *AuthHandler) Login(ctx context.Context, req *auth.LoginRequest) (*auth.LoginResponse, error) {
+	if err := validation.Validate(req); err != nil {
+		return nil, err
+	}
+
+	// Authenticate user
+	user, err := h.userRepo.AuthenticateUser(ctx, req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate access token
+	accessToken, err := h.authClient.GenerateJWT(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate refresh token
+	refreshToken, err := h.authClient.GenerateRefreshToken(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// VerifyToken validates the JWT token and returns user information
+func (h *AuthHandler) VerifyToken(ctx context.Context, req *auth.VerifyRequest) (*auth.VerifyResponse, error) {
+	if req.Token == "" {
+		return nil, errors.New("token is required")
+	}
+
+	claims, err := h.authClient.ValidateJWT(req.Token)
+	if err != nil {
+		return &auth.VerifyResponse{
+			Valid: false,
+		}, nil
+	}
+
+	// Get user ID from claims
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return &auth.VerifyResponse{
+			Valid: false,
+		}, nil
+	}
+
+	// Get user details from database
+	user, err := h.userRepo.GetUserByID(ctx, int(userID))
+	if err != nil {
+		return &auth.VerifyResponse{
+			Valid: false,
+		}, nil
+	}
+
+	return &auth.VerifyResponse{
+		Valid:    true,
+		Username: user.Username,
+		UserId:   int64(user.ID),
+	}, nil
+}
+
+// RefreshToken generates a new access token using a valid refresh token
+func (h *AuthHandler) RefreshToken(ctx context.Context, req *auth.RefreshRequest) (*auth.LoginResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, errors.New("refresh token is required")
+	}
+
+	// Generate new access token using refresh token
+	newAccessToken, err := h.authClient.RefreshAccessToken(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate new refresh token
+	claims, err := h.authClient.ValidateJWT(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New("invalid refresh token claims")
+	}
+
+	newRefreshToken, err := h.authClient.GenerateRefreshToken(int(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	// Revoke old refresh token
+	err = h.authClient.RevokeRefreshToken(req.RefreshToken)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to revoke old refresh token", "error", err)
+	}
+
+	return &auth.LoginResponse{
+		AccessToken:  newAccessToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+// LookupUser reports whether a username exists and is active, returning
+// NotFound for unknown users. Only services may call it.
+func (h *AuthHandler) LookupUser(ctx context.Context, req *auth.LookupUserRequest) (*auth.LookupUserResponse, error) {
+	if err := h.authenticateService(ctx, req); err != nil {
+		return nil, err
+	}
+
+	user, err := h.userRepo.GetUserByUsername(ctx, req.Username)
+	if errors.Is(err, store.ErrUserNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.LookupUserResponse{
+		UserId:   int64(user.ID),
+		Username: user.Username,
+		Active:   user.IsActive,
+	}, nil
+}
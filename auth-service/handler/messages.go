@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/RishangS/auth-service/validation"
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Messages configures the RPCs that change sent messages. Changes are
+// published to the messages topic so that ws-service can tell both users'
+// live sessions.
+type Messages struct {
+	Store           store.MessageStore
+	Publisher       *events.Publisher
+	EditWindow      time.Duration
+	MaxContentBytes int
+}
+
+// EditMessage replaces the content of a message the caller sent
+func (h *AuthHandler) EditMessage(ctx context.Context, req *auth.EditMessageRequest) (*auth.ChatMessage, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Content) > h.messages.MaxContentBytes {
+		return nil, validation.NewError([]validation.FieldViolation{{
+			Field:       "content",
+			Description: fmt.Sprintf("must be at most %d bytes", h.messages.MaxContentBytes),
+		}})
+	}
+
+	msg, err := h.participantMessage(ctx, userID, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, messageStatus(store.ErrMessageNotFound)
+	}
+	if msg.SenderID != userID {
+		return nil, status.Error(codes.PermissionDenied, "only the sender can edit a message")
+	}
+	if time.Since(msg.CreatedAt) > h.messages.EditWindow {
+		return nil, status.Errorf(codes.FailedPrecondition, "messages can only be edited within %s of sending", h.messages.EditWindow)
+	}
+
+	edited, err := h.messages.Store.UpdateMessage(ctx, msg.ID, req.Content)
+	if err != nil {
+		return nil, messageStatus(err)
+	}
+	h.publishChange(ctx, edited, func(sender, recipient string) *eventspb.ChatEvent {
+		return events.NewMessageEdited(&eventspb.MessageEdited{
+			MessageId: edited.EventID,
+			Sender:    sender,
+			Recipient: recipient,
+			Content:   edited.Content,
+		})
+	})
+	return messageProto(edited), nil
+}
+
+// DeleteMessage hides a message from the caller or deletes it for both
+// users, leaving a tombstone. A tombstone can still be hidden.
+func (h *AuthHandler) DeleteMessage(ctx context.Context, req *auth.DeleteMessageRequest) (*emptypb.Empty, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := h.participantMessage(ctx, userID, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.ForEveryone {
+		if err := h.messages.Store.HideMessage(ctx, msg.ID, userID); err != nil {
+			return nil, messageStatus(err)
+		}
+		return &emptypb.Empty{}, nil
+	}
+
+	if msg.SenderID != userID {
+		return nil, status.Error(codes.PermissionDenied, "only the sender can delete a message for everyone")
+	}
+	if err := h.messages.Store.DeleteMessage(ctx, msg.ID, userID); err != nil {
+		return nil, messageStatus(err)
+	}
+	h.publishChange(ctx, msg, func(sender, recipient string) *eventspb.ChatEvent {
+		return events.NewMessageDeleted(&eventspb.MessageDeleted{
+			MessageId: msg.EventID,
+			Sender:    sender,
+			Recipient: recipient,
+		})
+	})
+	return &emptypb.Empty{}, nil
+}
+
+// ListMessageEdits returns the earlier versions of a message
+func (h *AuthHandler) ListMessageEdits(ctx context.Context, req *auth.ListMessageEditsRequest) (*auth.ListMessageEditsResponse, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := h.participantMessage(ctx, userID, req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+
+	edits, err := h.messages.Store.ListMessageEdits(ctx, msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &auth.ListMessageEditsResponse{Edits: make([]*auth.MessageEdit, len(edits))}
+	for i, e := range edits {
+		resp.Edits[i] = &auth.MessageEdit{Content: e.Content, EditedAt: timestamppb.New(e.EditedAt)}
+	}
+	return resp, nil
+}
+
+// participantMessage returns the message with the given event ID if userID
+// sent or received it. Other users' messages are reported as not found.
+func (h *AuthHandler) participantMessage(ctx context.Context, userID int, eventID string) (*store.Message, error) {
+	msg, err := h.messages.Store.GetMessageByEventID(ctx, eventID)
+	if err != nil {
+		return nil, messageStatus(err)
+	}
+	if msg.SenderID != userID && msg.RecipientID != userID {
+		return nil, messageStatus(store.ErrMessageNotFound)
+	}
+	return msg, nil
+}
+
+// publishChange publishes the event built by newEvent for msg's sender and
+// recipient. The change is already stored, so a failure is only logged:
+// sessions that miss the event see the change when they next load history.
+func (h *AuthHandler) publishChange(ctx context.Context, msg *store.Message, newEvent func(sender, recipient string) *eventspb.ChatEvent) {
+	sender, err := h.participantName(ctx, msg.SenderID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking up message sender", "message_id", msg.EventID, "error", err)
+		return
+	}
+	recipient, err := h.participantName(ctx, msg.RecipientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking up message recipient", "message_id", msg.EventID, "error", err)
+		return
+	}
+
+	// Keyed by sender like the message itself, so the change is consumed after it
+	if err := h.messages.Publisher.Publish(ctx, sender, newEvent(sender, recipient)); err != nil {
+		slog.ErrorContext(ctx, "Error publishing message change", "message_id", msg.EventID, "error", err)
+	}
+}
+
+// participantName returns the username of a message's participant, or "" if
+// their account has been removed
+func (h *AuthHandler) participantName(ctx context.Context, userID int) (string, error) {
+	if userID == 0 {
+		return "", nil
+	}
+	user, err := h.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// messageStatus maps message store errors to gRPC status errors
+func messageStatus(err error) error {
+	if errors.Is(err, store.ErrMessageNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return err
+}
+
+func messageProto(m *store.Message) *auth.ChatMessage {
+	msg := &auth.ChatMessage{
+		Id:          m.EventID,
+		SenderId:    int64(m.SenderID),
+		RecipientId: int64(m.RecipientID),
+		ContentType: m.ContentType,
+		Content:     m.Content,
+		Metadata:    m.Metadata,
+		CreatedAt:   timestamppb.New(m.CreatedAt),
+	}
+	if m.EditedAt != nil {
+		msg.EditedAt = timestamppb.New(*m.EditedAt)
+	}
+	return msg
+}
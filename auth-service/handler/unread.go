@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/store"
+)
+
+// GetUnreadCounts returns the caller's unread message counts per conversation
+func (h *AuthHandler) GetUnreadCounts(ctx context.Context, req *auth.GetUnreadCountsRequest) (*auth.GetUnreadCountsResponse, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := h.messages.Store.GetUnreadCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &auth.GetUnreadCountsResponse{Conversations: make([]*auth.UnreadCount, len(counts))}
+	for i, c := range counts {
+		resp.Conversations[i] = &auth.UnreadCount{Username: c.PeerUsername, UnreadCount: int64(c.Count)}
+		resp.TotalUnread += int64(c.Count)
+	}
+	return resp, nil
+}
+
+// MarkConversationRead moves the caller's read cursor for a conversation
+// forward and tells the caller's live sessions the new counts
+func (h *AuthHandler) MarkConversationRead(ctx context.Context, req *auth.MarkConversationReadRequest) (*auth.MarkConversationReadResponse, error) {
+	userID, err := h.authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := h.userRepo.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, contactStatus(err)
+	}
+
+	// The cursor may stop at a message either user sent; the store checks
+	// that it is part of this conversation
+	upTo := 0
+	if req.MessageId != "" {
+		msg, err := h.participantMessage(ctx, userID, req.MessageId)
+		if err != nil {
+			return nil, err
+		}
+		upTo = msg.ID
+	}
+
+	unread, err := h.messages.Store.MarkConversationRead(ctx, userID, peer.ID, upTo)
+	if err != nil {
+		if errors.Is(err, store.ErrMessageNotFound) {
+			return nil, messageStatus(err)
+		}
+		return nil, contactStatus(err)
+	}
+	total, err := h.messages.Store.GetUnreadCount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &auth.MarkConversationReadResponse{
+		Conversation: &auth.UnreadCount{Username: peer.Username, UnreadCount: int64(unread)},
+		TotalUnread:  int64(total),
+	}
+	h.publishRead(ctx, userID, resp)
+	return resp, nil
+}
+
+// publishRead publishes the caller's new counts, keyed by the caller so that
+// their reads are consumed in order. Like publishChange it only logs failures.
+func (h *AuthHandler) publishRead(ctx context.Context, userID int, resp *auth.MarkConversationReadResponse) {
+	reader, err := h.participantName(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking up reader", "user_id", userID, "error", err)
+		return
+	}
+
+	event := events.NewConversationRead(&eventspb.ConversationRead{
+		Reader:      reader,
+		Peer:        resp.Conversation.Username,
+		UnreadCount: resp.Conversation.UnreadCount,
+		TotalUnread: resp.TotalUnread,
+	})
+	if err := h.messages.Publisher.Publish(ctx, reader, event); err != nil {
+		slog.ErrorContext(ctx, "Error publishing conversation read", "user_id", userID, "error", err)
+	}
+}
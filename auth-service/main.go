@@ -1,77 +1,283 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net"
-	"net/http"
-
-	auth "github.com/RishangS/auth-service/gen/proto"
-	"github.com/RishangS/auth-service/handler"
-	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/reflection"
-)
-
-const (
-	grpcPort = "50051"
-	httpPort = "8080"
-)
-
-func main() {
-	// Create context that listens for interrupt signal
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Initialize auth server
-	authServer := handler.NewAuthHandler()
-
-	// Create gRPC server
-	grpcServer := grpc.NewServer()
-	auth.RegisterAuthServiceServer(grpcServer, authServer)
-	reflection.Register(grpcServer) // Enable reflection for testing with grpcurl
-
-	// Start gRPC server
-	grpcLis, err := net.Listen("tcp", ":"+grpcPort)
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
-	}
-	go func() {
-		log.Printf("gRPC server listening on :%s", grpcPort)
-		if err := grpcServer.Serve(grpcLis); err != nil {
-			log.Fatalf("failed to serve: %v", err)
-		}
-	}()
-
-	// Create gRPC-Gateway mux
-	gwMux := runtime.NewServeMux()
-
-	// Register gRPC-Gateway endpoints
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()), // Updated to use new API
-	}
-	err = auth.RegisterAuthServiceHandlerFromEndpoint(ctx, gwMux, "localhost:"+grpcPort, opts)
-	if err != nil {
-		log.Fatalf("failed to register gateway: %v", err)
-	}
-
-	// Add health check endpoint to gwMux
-	gwMux.HandlePath("GET", "/health", func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	// Create HTTP server
-	httpServer := &http.Server{
-		Addr:    ":" + httpPort,
-		Handler: gwMux,
-	}
-
-	// Start HTTP server
-	log.Printf("HTTP server listening on :%s", httpPort)
-	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("failed to serve: %v", err)
-	}
-
-}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/RishangS/auth-service/handler"
+	"github.com/RishangS/auth-service/utils"
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/cors"
+	"github.com/RishangS/shared/events"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/health"
+	"github.com/RishangS/shared/kafkaclient"
+	"github.com/RishangS/shared/logging"
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/migrations"
+	"github.com/RishangS/shared/store"
+	"github.com/RishangS/shared/tlsconfig"
+	"github.com/RishangS/shared/tracing"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func main() {
+	// "auth-service migrate [up|down [n]|version]" manages the schema and
+	// exits; it needs only the database settings
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(loadMigrateConfig(), os.Args[2:])
+		return
+	}
+
+	// Env and CONFIG_FILE settings, validated before anything starts
+	cfg := loadConfig()
+	grpcPort := strconv.Itoa(cfg.GRPCPort)
+	httpPort := strconv.Itoa(cfg.HTTPPort)
+
+	migrate := flag.Bool("migrate", false, "apply pending database migrations before starting")
+	flag.Parse()
+
+	// Create context that listens for interrupt signal
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The gateway keeps its connection until the HTTP server has drained
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *migrate {
+		runMigrate(cfg.Database, []string{"up"})
+	}
+
+	// Traces are exported according to OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Init(ctx, "auth-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing()
+
+	// Initialize auth server
+	db := store.OpenDB(cfg.Database)
+	defer db.Close()
+	passwordPolicy, err := utils.NewPasswordPolicy(cfg.Password.MinLength, cfg.Password.MinCharClasses, cfg.Password.BreachedList)
+	if err != nil {
+		logging.Fatal("Failed to build password policy", "error", err)
+	}
+
+	// Message edits and deletions are published to the topic ws-service
+	// delivers from, so both users' live sessions are told about them
+	kafkaClient, err := kafkaclient.New(ctx, cfg.Kafka.Kafka, "auth-service")
+	if err != nil {
+		logging.Fatal("Failed to configure Kafka client", "error", err)
+	}
+	topicsCtx, cancelTopics := context.WithTimeout(ctx, cfg.Kafka.Timeout)
+	err = kafkaClient.EnsureTopics(topicsCtx, []kafkaclient.TopicSpec{
+		{Name: cfg.Kafka.MessagesTopic, Topic: cfg.Kafka.MessagesTopicConfig},
+	}, cfg.Kafka.CreateTopics)
+	cancelTopics()
+	if err != nil {
+		logging.Fatal("Kafka topics are not usable", "error", err)
+	}
+	publisher := events.NewPublisher(kafkaClient.Writer(cfg.Kafka.MessagesTopic, cfg.Kafka.MessagesProducer), cfg.Kafka.WriteTimeout)
+	defer func() {
+		if err := publisher.Close(); err != nil {
+			slog.Error("Error closing messages writer", "error", err)
+		}
+	}()
+
+	authServer := handler.NewAuthHandler(
+		store.NewPostgresUserStore(db, cfg.Database.QueryTimeout),
+		store.NewPostgresContactStore(db, cfg.Database.QueryTimeout),
+		handler.Messages{
+			Store:           store.NewPostgresMessageStore(db, cfg.Database.QueryTimeout),
+			Publisher:       publisher,
+			EditWindow:      cfg.Messages.EditWindow,
+			MaxContentBytes: cfg.Messages.MaxContentBytes,
+		},
+		utils.NewAuthClient(cfg.JWTSecret),
+		passwordPolicy,
+		cfg.ServiceToken,
+	)
+
+	// Readiness depends on the database; liveness only on the process. Kafka
+	// is left out so that a broker outage does not stop logins.
+	checker := health.NewChecker(cfg.Lifecycle.HealthCheckTimeout)
+	checker.Add("postgres", health.Postgres(db))
+
+	// Certificates for both listeners, reloaded from disk when rotated
+	var certs *tlsconfig.Reloader
+	if cfg.TLS.Enabled() {
+		certs, err = tlsconfig.Start(ctx, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, cfg.TLS.ReloadInterval)
+		if err != nil {
+			logging.Fatal("Failed to load TLS certificates", "error", err)
+		}
+	}
+
+	// Create gRPC server with per-RPC metrics and tracing
+	grpcMetrics := metrics.NewGRPCServerMetrics()
+	serverOpts := []grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(grpcMetrics.UnaryServerInterceptor(), logging.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(grpcMetrics.StreamServerInterceptor()),
+	}
+	if certs != nil {
+		// With TLS_CLIENT_AUTH, callers such as ws-service must present a
+		// certificate signed by TLS_CA_FILE
+		tlsCfg, err := certs.ServerConfig(cfg.TLS.ClientAuth)
+		if err != nil {
+			logging.Fatal("Failed to configure gRPC TLS", "error", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	auth.RegisterAuthServiceServer(grpcServer, authServer)
+	reflection.Register(grpcServer) // Enable reflection for testing with grpcurl
+
+	// Standard gRPC health service, kept in step with the readiness checks
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go checker.WatchGRPC(ctx, healthServer, 10*time.Second, auth.AuthService_ServiceDesc.ServiceName)
+	grpcMetrics.InitializeMetrics(grpcServer)
+
+	// Start gRPC server
+	grpcLis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		logging.Fatal("Failed to listen", "port", grpcPort, "error", err)
+	}
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("gRPC server listening", "port", grpcPort, "tls", certs != nil, "client_auth", cfg.TLS.ClientAuth)
+		if err := grpcServer.Serve(grpcLis); err != nil {
+			serveErr <- fmt.Errorf("gRPC server: %w", err)
+		}
+	}()
+
+	// Create gRPC-Gateway mux
+	gwMux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher))
+
+	// Register gRPC-Gateway endpoints. Over TLS the gateway presents the
+	// service's own certificate, which must therefore be valid for localhost.
+	creds := insecure.NewCredentials()
+	if certs != nil {
+		creds = certs.ClientCredentials("localhost")
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		tracing.DialOption(),
+	}
+	err = auth.RegisterAuthServiceHandlerFromEndpoint(ctx, gwMux, "localhost:"+grpcPort, opts)
+	if err != nil {
+		logging.Fatal("Failed to register gateway", "error", err)
+	}
+
+	// Serve the probes and metrics next to the gateway routes, which browser
+	// pages on CORS_ALLOWED_ORIGINS may call
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	metrics.Register(mux)
+	mux.Handle("/", cors.Middleware(cfg.CORS, gwMux))
+
+	// Create HTTP server
+	httpServer := &http.Server{
+		Addr:    ":" + httpPort,
+		Handler: mux,
+	}
+
+	// Browsers and probes are not asked for client certificates
+	if certs != nil {
+		httpServer.TLSConfig, err = certs.ServerConfig(false)
+		if err != nil {
+			logging.Fatal("Failed to configure HTTP TLS", "error", err)
+		}
+	}
+
+	// Start HTTP server
+	go func() {
+		slog.Info("HTTP server listening", "port", httpPort, "tls", certs != nil)
+		var err error
+		if certs != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
+
+	select {
+	case <-sigCtx.Done():
+		slog.Info("Shutdown signal received, draining connections")
+	case err := <-serveErr:
+		slog.Error("Server failed, shutting down", "error", err)
+	}
+
+	// Fail readiness so no new traffic is routed here while draining
+	checker.Drain()
+	healthServer.Shutdown()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Lifecycle.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Stop accepting HTTP requests and wait for in-flight ones
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server shutdown", "error", err)
+	}
+	cancel()
+
+	// Let in-flight RPCs finish, forcing the stop once the deadline passes
+	gracefulStop(shutdownCtx, grpcServer)
+
+	slog.Info("Auth service stopped")
+}
+
+// gracefulStop waits for grpcServer to finish pending RPCs or for ctx to expire
+func gracefulStop(ctx context.Context, grpcServer *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("gRPC graceful stop timed out, forcing stop")
+		grpcServer.Stop()
+	}
+}
+
+// incomingHeaderMatcher forwards X-Request-Id to the gRPC handlers so REST
+// calls are logged under the caller's request ID
+func incomingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, logging.RequestIDHeader) {
+		return logging.RequestIDHeader, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// runMigrate runs a migrate subcommand against the configured database
+func runMigrate(cfg config.Database, args []string) {
+	db := store.OpenDB(cfg)
+	defer db.Close()
+
+	if err := migrations.RunCommand(context.Background(), db, args); err != nil {
+		logging.Fatal("Migration failed", "error", err)
+	}
+}
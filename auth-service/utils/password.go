@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// PasswordPolicy enforces strength requirements on new passwords
+type PasswordPolicy struct {
+	minLength      int
+	minCharClasses int
+	breached       map[string]struct{}
+}
+
+// NewPasswordPolicy builds a policy requiring minLength characters from at
+// least minCharClasses classes, rejecting the passwords listed in the optional
+// breachedList file
+func NewPasswordPolicy(minLength, minCharClasses int, breachedList string) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		minLength:      minLength,
+		minCharClasses: minCharClasses,
+		breached:       make(map[string]struct{}),
+	}
+
+	if breachedList != "" {
+		if err := policy.loadBreachedList(breachedList); err != nil {
+			return nil, fmt.Errorf("error loading breached password list: %w", err)
+		}
+		slog.Info("Loaded breached password list", "path", breachedList, "count", len(policy.breached))
+	}
+
+	return policy, nil
+}
+
+// loadBreachedList reads one password per line, ignoring blanks and # comments
+func (p *PasswordPolicy) loadBreachedList(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check returns the reasons the password is rejected, or nil if it is acceptable
+func (p *PasswordPolicy) Check(password, username string) []string {
+	var reasons []string
+
+	if len([]rune(password)) < p.minLength {
+		reasons = append(reasons, fmt.Sprintf("must be at least %d characters", p.minLength))
+	}
+
+	if classes := charClasses(password); classes < p.minCharClasses {
+		reasons = append(reasons, fmt.Sprintf("must mix at least %d of lowercase, uppercase, digits and symbols", p.minCharClasses))
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		reasons = append(reasons, "must not contain the username")
+	}
+
+	if _, found := p.breached[strings.ToLower(password)]; found {
+		reasons = append(reasons, "appears in a list of breached passwords")
+	}
+
+	return reasons
+}
+
+// charClasses counts the distinct character classes used in s
+func charClasses(s string) int {
+	var lower, upper, digit, other bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
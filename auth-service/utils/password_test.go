@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestPasswordPolicyCheck checks each rule on its own and that every broken
+// rule is reported
+func TestPasswordPolicyCheck(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(list, []byte("# common passwords\n\nPassw0rd!\n  Summer2024!  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(8, 3, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		tooShort  = "must be at least 8 characters"
+		tooSimple = "must mix at least 3 of lowercase, uppercase, digits and symbols"
+		username  = "must not contain the username"
+		breached  = "appears in a list of breached passwords"
+	)
+	for _, tc := range []struct {
+		password string
+		want     []string
+	}{
+		{password: "Correct-horse7"},
+		{password: "Ab1!", want: []string{tooShort}},
+		// Length counts characters, not bytes
+		{password: "Ééééééé1", want: nil},
+		{password: "alllowercase", want: []string{tooSimple}},
+		{password: "xAlice-2024", want: []string{username}},
+		{password: "passw0rd!", want: []string{breached}},
+		{password: "summer2024!", want: []string{breached}},
+		{password: "alice", want: []string{tooShort, tooSimple, username}},
+	} {
+		if got := policy.Check(tc.password, "alice"); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: got %q, want %q", tc.password, got, tc.want)
+		}
+	}
+}
+
+// TestNewPasswordPolicyMissingList checks that a configured list that
+// cannot be read stops startup rather than being skipped
+func TestNewPasswordPolicyMissingList(t *testing.T) {
+	if _, err := NewPasswordPolicy(8, 3, filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("missing breached password list was accepted")
+	}
+	policy, err := NewPasswordPolicy(8, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.Check("Correct-horse7", ""); got != nil {
+		t.Errorf("got %q without a list, want no reasons", got)
+	}
+}
@@ -0,0 +1,145 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// emailPattern mirrors the email_check constraint on the users table
+var emailPattern = regexp.MustCompile(`^[A-Za-z0-9._%-]+@[A-Za-z0-9.-]+[.][A-Za-z]+$`)
+
+// patterns caches compiled field patterns keyed by expression
+var patterns sync.Map
+
+// FieldViolation describes why a single field was rejected
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error aggregates every field violation found in a request
+type Error struct {
+	Violations []FieldViolation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// GRPCStatus converts the error into InvalidArgument with BadRequest details
+// so that clients receive the violations field by field
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+	br := &errdetails.BadRequest{}
+	for _, v := range e.Violations {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	if detailed, err := st.WithDetails(br); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// NewError returns an *Error for the given violations, or nil when there are none
+func NewError(violations []FieldViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: violations}
+}
+
+// Check evaluates the (auth.rules) options declared on the message fields
+func Check(msg proto.Message) []FieldViolation {
+	var violations []FieldViolation
+
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.StringKind || fd.IsList() || fd.IsMap() {
+			continue
+		}
+
+		rules, ok := proto.GetExtension(fd.Options(), auth.E_Rules).(*auth.FieldRules)
+		if !ok || rules == nil {
+			continue
+		}
+
+		if desc := checkString(m.Get(fd).String(), rules); desc != "" {
+			violations = append(violations, FieldViolation{
+				Field:       string(fd.Name()),
+				Description: desc,
+			})
+		}
+	}
+
+	return violations
+}
+
+// Validate is Check wrapped into an error suitable for returning from a handler
+func Validate(msg proto.Message) error {
+	return NewError(Check(msg))
+}
+
+// checkString returns a description of the first rule the value breaks
+func checkString(value string, rules *auth.FieldRules) string {
+	if value == "" {
+		if rules.GetRequired() {
+			return "is required"
+		}
+		return ""
+	}
+
+	length := utf8.RuneCountInString(value)
+	if min := int(rules.GetMinLen()); length < min {
+		return fmt.Sprintf("must be at least %d characters", min)
+	}
+	if max := int(rules.GetMaxLen()); max > 0 && length > max {
+		return fmt.Sprintf("must be at most %d characters", max)
+	}
+
+	if expr := rules.GetPattern(); expr != "" {
+		re, err := compile(expr)
+		if err != nil {
+			return "has an invalid validation pattern"
+		}
+		if !re.MatchString(value) {
+			return "contains invalid characters"
+		}
+	}
+
+	if rules.GetEmail() && !emailPattern.MatchString(value) {
+		return "must be a valid email address"
+	}
+
+	return ""
+}
+
+func compile(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestCheck checks the rules declared on SignupRequest, one broken rule
+// per case
+func TestCheck(t *testing.T) {
+	valid := func() *auth.SignupRequest {
+		return &auth.SignupRequest{Username: "alice_1", Password: "secret", Email: "alice@example.com"}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(*auth.SignupRequest)
+		want   []FieldViolation
+	}{
+		{name: "valid", modify: func(*auth.SignupRequest) {}},
+		{
+			name:   "missing fields",
+			modify: func(r *auth.SignupRequest) { r.Username, r.Email = "", "" },
+			want:   []FieldViolation{{"username", "is required"}, {"email", "is required"}},
+		},
+		{
+			name:   "too short",
+			modify: func(r *auth.SignupRequest) { r.Username = "al" },
+			want:   []FieldViolation{{"username", "must be at least 3 characters"}},
+		},
+		{
+			name:   "too long",
+			modify: func(r *auth.SignupRequest) { r.Username = strings.Repeat("a", 33) },
+			want:   []FieldViolation{{"username", "must be at most 32 characters"}},
+		},
+		{
+			// Lengths count characters, not bytes
+			name:   "multibyte within limit",
+			modify: func(r *auth.SignupRequest) { r.Password = strings.Repeat("é", 128) },
+		},
+		{
+			name:   "pattern",
+			modify: func(r *auth.SignupRequest) { r.Username = "alice!" },
+			want:   []FieldViolation{{"username", "contains invalid characters"}},
+		},
+		{
+			name:   "email",
+			modify: func(r *auth.SignupRequest) { r.Email = "alice@example" },
+			want:   []FieldViolation{{"email", "must be a valid email address"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := valid()
+			tc.modify(req)
+			if got := Check(req); !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestValidateStatus checks that violations reach clients as
+// InvalidArgument with one BadRequest field violation each
+func TestValidateStatus(t *testing.T) {
+	if err := Validate(&auth.SignupRequest{Username: "alice", Password: "secret", Email: "a@example.com"}); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+
+	err := Validate(&auth.SignupRequest{Username: "a!", Password: "secret"})
+	var verr *Error
+	if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+		t.Fatalf("got %v, want two violations", err)
+	}
+
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("got code %s, want InvalidArgument", st.Code())
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	if want := []string{"username", "email"}; !slices.Equal(fields, want) {
+		t.Errorf("got field violations for %v, want %v", fields, want)
+	}
+}
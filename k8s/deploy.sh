@@ -23,15 +23,11 @@ cd ..
 
 # Build persistence service
 echo "Building persistence-service..."
-cd persistence-service
-docker build -f Dockerfile.k8s -t persistence-service:latest .
-cd ..
+docker build -f persistence-service/Dockerfile.k8s -t persistence-service:latest .
 
 # Build WebSocket service
 echo "Building ws-service..."
-cd ws-service
-docker build -f Dockerfile.k8s -t ws-service:latest .
-cd ..
+docker build -f ws-service/Dockerfile.k8s -t ws-service:latest .
 
 echo "🔧 Applying Kubernetes manifests..."
 
@@ -51,7 +47,6 @@ kubectl apply -f k8s/kafka-deployment.yaml
 kubectl apply -f k8s/kafka-service.yaml
 # Apply Kafka topics (Strimzi)
 kubectl apply -f k8s/messages-topic.yaml
-kubectl apply -f k8s/persist-topic.yaml
 
 # Wait for infrastructure to be ready
 echo "⏳ Waiting for infrastructure to be ready..."
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/logging"
+)
+
+// Config is the persistence-service configuration, loaded by config.Load
+type Config struct {
+	HTTPPort int `yaml:"http_port" env:"HTTP_PORT" default:"8080"`
+
+	TLS       config.TLS       `yaml:"tls"`
+	Kafka     KafkaConfig      `yaml:"kafka"`
+	Purge     PurgeConfig      `yaml:"purge"`
+	Database  config.Database  `yaml:"database"`
+	Log       config.Log       `yaml:"log"`
+	Lifecycle config.Lifecycle `yaml:"lifecycle"`
+}
+
+// KafkaConfig selects the topic and consumer group to persist from. The topic
+// is the one ws-service publishes to and delivers from; unread count changes
+// are published back to it.
+type KafkaConfig struct {
+	config.Kafka `yaml:",inline"`
+	Topic        string        `yaml:"topic" env:"KAFKA_TOPIC" default:"messages"`
+	GroupID      string        `yaml:"group_id" env:"KAFKA_GROUP_ID" default:"persistence-group"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"KAFKA_WRITE_TIMEOUT" default:"5s"`
+
+	TopicConfig config.Topic    `yaml:"topic_config" env_prefix:"KAFKA_MESSAGES_"`
+	Producer    config.Producer `yaml:"producer" env_prefix:"KAFKA_MESSAGES_"`
+
+	// Backoff bounds for retrying a message whose insert failed
+	RetryMin time.Duration `yaml:"retry_min" env:"PERSIST_RETRY_MIN" default:"500ms"`
+	RetryMax time.Duration `yaml:"retry_max" env:"PERSIST_RETRY_MAX" default:"30s"`
+}
+
+// PurgeConfig schedules the job that permanently removes messages nobody can
+// see any more: tombstones of messages deleted for everyone, and messages
+// every participant deleted for themselves. A zero interval disables it.
+type PurgeConfig struct {
+	Retention time.Duration `yaml:"retention" env:"MESSAGE_RETENTION" default:"720h"`
+	Interval  time.Duration `yaml:"interval" env:"MESSAGE_PURGE_INTERVAL" default:"1h"`
+	BatchSize int           `yaml:"batch_size" env:"MESSAGE_PURGE_BATCH_SIZE" default:"1000"`
+}
+
+// Validate checks that the retention and batch size are positive
+func (p *PurgeConfig) Validate() []string {
+	var problems []string
+	if p.Retention <= 0 {
+		problems = append(problems, "MESSAGE_RETENTION must be positive")
+	}
+	if p.Interval < 0 {
+		problems = append(problems, "MESSAGE_PURGE_INTERVAL must not be negative")
+	}
+	if p.BatchSize < 1 {
+		problems = append(problems, "MESSAGE_PURGE_BATCH_SIZE must be at least 1")
+	}
+	return problems
+}
+
+// Validate checks the HTTP port, retry bounds and write timeout
+func (c *Config) Validate() []string {
+	problems := config.ValidPort("HTTP_PORT", c.HTTPPort)
+	if c.Kafka.WriteTimeout <= 0 {
+		problems = append(problems, "KAFKA_WRITE_TIMEOUT must be positive")
+	}
+	if c.Kafka.RetryMin <= 0 || c.Kafka.RetryMax < c.Kafka.RetryMin {
+		problems = append(problems, "PERSIST_RETRY_MIN must be positive and not exceed PERSIST_RETRY_MAX")
+	}
+	if c.TLS.ClientAuth {
+		problems = append(problems, "TLS_CLIENT_AUTH is not supported on the health listener")
+	}
+	return problems
+}
+
+// loadConfig loads the configuration, exiting with every problem listed if it
+// is invalid, then sets up logging and prints the effective values
+func loadConfig() Config {
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Init("persistence-service", cfg.Log.Level, cfg.Log.Format)
+	slog.Info("Effective configuration", "config", config.Redact(&cfg))
+	return cfg
+}
+
+// migrateConfig is the part of Config the migrate subcommand uses, so that
+// schema changes do not need the settings only the running service needs
+type migrateConfig struct {
+	Database config.Database `yaml:"database"`
+	Log      config.Log      `yaml:"log"`
+}
+
+// loadMigrateConfig loads only the database and log settings, exiting like
+// loadConfig if they are invalid
+func loadMigrateConfig() config.Database {
+	var cfg migrateConfig
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Init("persistence-service", cfg.Log.Level, cfg.Log.Format)
+	slog.Info("Effective configuration", "config", config.Redact(&cfg))
+	return cfg.Database
+}
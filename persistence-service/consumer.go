@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/RishangS/shared/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+// messageReader is the part of *kafka.Reader the consumer uses
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// consume fetches messages until ctx is done, persisting each one before
+// committing its offset. Persisting and committing run under workCtx, so the
+// message in hand when ctx ends can still finish; if workCtx ends first, its
+// offset is left uncommitted and the message is redelivered.
+func consume(ctx, workCtx context.Context, reader messageReader, cfg KafkaConfig, persist func(context.Context, kafka.Message) error) {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				slog.Info("Shutdown signal received, persistence service stopping")
+				return
+			}
+			slog.Error("Error reading message", "error", err)
+			continue
+		}
+		metrics.ObserveConsume(cfg.GroupID, msg)
+
+		// Retry transient failures so an offset is only committed once its
+		// message is stored or unusable
+		err = persistWithRetry(workCtx, cfg.RetryMin, cfg.RetryMax, func(ctx context.Context) error {
+			return persist(ctx, msg)
+		})
+		if err != nil {
+			if workCtx.Err() != nil {
+				slog.Warn("Shutdown deadline exceeded while persisting", "partition", msg.Partition, "offset", msg.Offset)
+				return
+			}
+			slog.Error("Skipping message that cannot be persisted", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		}
+
+		// Commit only once the message has been handled
+		if err := reader.CommitMessages(workCtx, msg); err != nil {
+			persistFailures.WithLabelValues(reasonCommit).Inc()
+			slog.Error("Error committing offset", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader hands out queued messages and blocks like kafka.Reader when
+// none are left
+type fakeReader struct {
+	messages chan kafka.Message
+
+	mu        sync.Mutex
+	committed []int64
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{messages: make(chan kafka.Message, len(msgs))}
+	for _, m := range msgs {
+		r.messages <- m
+	}
+	return r
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-r.messages:
+		return m, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		r.committed = append(r.committed, m.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) commits() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.committed...)
+}
+
+var testKafka = KafkaConfig{GroupID: "test", RetryMin: time.Millisecond, RetryMax: 10 * time.Millisecond}
+
+// returnsWithin fails the test if fn has not returned within d
+func returnsWithin(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("did not return within %s", d)
+	}
+}
+
+func TestConsumeCommitsHandledMessages(t *testing.T) {
+	reader := newFakeReader(kafka.Message{Offset: 1}, kafka.Message{Offset: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	persisted := make(chan int64, 2)
+	persist := func(ctx context.Context, msg kafka.Message) error {
+		persisted <- msg.Offset
+		if msg.Offset == 2 {
+			return errPermanent
+		}
+		return nil
+	}
+	returnsWithin(t, time.Second, func() {
+		go func() {
+			<-persisted
+			<-persisted
+			cancel()
+		}()
+		consume(ctx, context.Background(), reader, testKafka, persist)
+	})
+
+	// A message that can never be stored is skipped, so its offset is committed too
+	if got := reader.commits(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("committed offsets %v, want [1 2]", got)
+	}
+}
+
+func TestConsumeReturnsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	returnsWithin(t, time.Second, func() {
+		consume(ctx, context.Background(), newFakeReader(), testKafka, func(context.Context, kafka.Message) error {
+			t.Error("persist called without a message")
+			return nil
+		})
+	})
+}
+
+func TestConsumeLeavesOffsetWhenWorkCancelled(t *testing.T) {
+	reader := newFakeReader(kafka.Message{Offset: 7})
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancelWork)
+
+	returnsWithin(t, time.Second, func() {
+		consume(context.Background(), workCtx, reader, testKafka, func(ctx context.Context, msg kafka.Message) error {
+			return errors.New("database unavailable")
+		})
+	})
+	if got := reader.commits(); len(got) != 0 {
+		t.Errorf("committed offsets %v after shutdown, want none", got)
+	}
+}
+
+func TestPersistWithRetryReturnsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	transient := errors.New("database unavailable")
+	attempts := 0
+	var err error
+	returnsWithin(t, time.Second, func() {
+		err = persistWithRetry(ctx, time.Millisecond, time.Hour, func(context.Context) error {
+			attempts++
+			return transient
+		})
+	})
+	if !errors.Is(err, transient) {
+		t.Errorf("got %v, want the last attempt's error", err)
+	}
+	if attempts < 2 {
+		t.Errorf("made %d attempts, want retries before the cancellation", attempts)
+	}
+}
+
+func TestPersistWithRetryAbortsAttemptWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// An attempt blocked on a query ends with the context
+	var err error
+	returnsWithin(t, time.Second, func() {
+		err = persistWithRetry(ctx, time.Millisecond, time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestPersistWithRetryStopsOnPermanentError(t *testing.T) {
+	attempts := 0
+	err := persistWithRetry(context.Background(), time.Millisecond, time.Millisecond, func(context.Context) error {
+		attempts++
+		return errPermanent
+	})
+	if !errors.Is(err, errPermanent) || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want errPermanent after 1", err, attempts)
+	}
+}
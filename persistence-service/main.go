@@ -1,112 +1,278 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"os"
-	"time"
-
-	"github.com/RishangS/auth-service/utils"
-	"github.com/segmentio/kafka-go"
-)
-
-func main() {
-	// Initialize database connection
-	db := utils.NewDBService()
-
-	// Get Kafka configuration from environment
-	kafkaBrokers := getEnv("KAFKA_BROKERS", "localhost:9092")
-	kafkaTopic := getEnv("KAFKA_TOPIC", "persist")
-	kafkaGroupID := getEnv("KAFKA_GROUP_ID", "persistence-group")
-
-	// Create Kafka reader for persist topic
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{kafkaBrokers},
-		Topic:    kafkaTopic,
-		GroupID:  kafkaGroupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
-		MaxWait:  1 * time.Second,
-	})
-	defer reader.Close()
-
-	log.Printf("Persistence service started. Listening for messages on topic: %s", kafkaTopic)
-
-	for {
-		msg, err := reader.ReadMessage(context.Background())
-		if err != nil {
-			log.Printf("Error reading message: %v", err)
-			continue
-		}
-
-		// Process and persist the message
-		if err := processAndPersist(db, msg); err != nil {
-			log.Printf("Error processing message: %v", err)
-		}
-	}
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-// processAndPersist handles the complete message processing pipeline
-func processAndPersist(db *utils.UserRepository, msg kafka.Message) error {
-	// Parse message metadata from headers
-	metadata, err := extractMessageMetadata(msg)
-	if err != nil {
-		return err
-	}
-
-	// Create message in database
-	if _, err := db.CreateMessage(metadata.From, metadata.To, string(msg.Value)); err != nil {
-		return fmt.Errorf("error creating message: %w", err)
-	}
-
-	log.Printf("Persisted message from %s to %s", metadata.From, metadata.To)
-	return nil
-}
-
-// MessageMetadata contains extracted message information
-type MessageMetadata struct {
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	Timestamp time.Time `json:"timestamp"`
-}
-
-// extractMessageMetadata extracts metadata from Kafka message headers
-func extractMessageMetadata(msg kafka.Message) (*MessageMetadata, error) {
-	metadata := &MessageMetadata{}
-
-	for _, header := range msg.Headers {
-		switch header.Key {
-		case "From":
-			metadata.From = string(header.Value)
-		case "To":
-			metadata.To = string(header.Value)
-		case "Timestamp":
-			t, err := time.Parse(time.RFC3339, string(header.Value))
-			if err != nil {
-				return nil, fmt.Errorf("invalid timestamp format: %w", err)
-			}
-			metadata.Timestamp = t
-		}
-	}
-
-	// Validate required fields
-	if metadata.From == "" || metadata.To == "" {
-		return nil, fmt.Errorf("missing required message headers")
-	}
-
-	// Set current time if timestamp not provided
-	if metadata.Timestamp.IsZero() {
-		metadata.Timestamp = time.Now()
-	}
-
-	return metadata, nil
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	"github.com/RishangS/shared/health"
+	"github.com/RishangS/shared/kafkaclient"
+	"github.com/RishangS/shared/logging"
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/migrations"
+	"github.com/RishangS/shared/store"
+	"github.com/RishangS/shared/tlsconfig"
+	"github.com/RishangS/shared/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("github.com/RishangS/persistence-service")
+
+func main() {
+	// "persistence-service migrate [up|down [n]|version]" manages the schema
+	// and exits; it needs only the database settings
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(loadMigrateConfig(), os.Args[2:])
+		return
+	}
+
+	// Env and CONFIG_FILE settings, validated before anything starts
+	cfg := loadConfig()
+
+	migrate := flag.Bool("migrate", false, "apply pending database migrations before starting")
+	flag.Parse()
+
+	if *migrate {
+		runMigrate(cfg.Database, []string{"up"})
+	}
+
+	// Cancelled on SIGINT/SIGTERM to stop fetching new messages
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The message being persisted when the signal arrives gets SHUTDOWN_TIMEOUT
+	// to finish and commit its offset before its queries are aborted
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	context.AfterFunc(ctx, func() {
+		time.AfterFunc(cfg.Lifecycle.ShutdownTimeout, cancelWork)
+	})
+
+	// Traces are exported according to OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Init(ctx, "persistence-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing()
+
+	// Initialize database connection
+	db := store.OpenDB(cfg.Database)
+	defer db.Close()
+	messages := store.NewPostgresMessageStore(db, cfg.Database.QueryTimeout)
+
+	// Brokers, TLS and SASL settings shared by the topic check and the reader
+	kafkaClient, err := kafkaclient.New(ctx, cfg.Kafka.Kafka, "persistence-service")
+	if err != nil {
+		logging.Fatal("Failed to configure Kafka client", "error", err)
+	}
+
+	// Create or validate the topic before joining the consumer group
+	topicsCtx, cancelTopics := context.WithTimeout(ctx, cfg.Kafka.Timeout)
+	err = kafkaClient.EnsureTopics(topicsCtx, []kafkaclient.TopicSpec{
+		{Name: cfg.Kafka.Topic, Topic: cfg.Kafka.TopicConfig},
+	}, cfg.Kafka.CreateTopics)
+	cancelTopics()
+	if err != nil {
+		logging.Fatal("Kafka topics are not usable", "error", err)
+	}
+
+	// Unread counts go back to the topic for ws-service to deliver
+	publisher := events.NewPublisher(kafkaClient.Writer(cfg.Kafka.Topic, cfg.Kafka.Producer), cfg.Kafka.WriteTimeout)
+	defer func() {
+		if err := publisher.Close(); err != nil {
+			slog.Error("Error closing messages writer", "error", err)
+		}
+	}()
+
+	// Create Kafka reader for persist topic
+	kafkaGroupID := cfg.Kafka.GroupID
+	reader := kafkaClient.Reader(kafka.ReaderConfig{
+		Topic:    cfg.Kafka.Topic,
+		GroupID:  kafkaGroupID,
+		MinBytes: 10e3, // 10KB
+		MaxBytes: 10e6, // 10MB
+		MaxWait:  1 * time.Second,
+	})
+	defer reader.Close()
+
+	// Readiness requires the database and Kafka; liveness only the process
+	checker := health.NewChecker(cfg.Lifecycle.HealthCheckTimeout)
+	checker.Add("postgres", health.Postgres(db))
+	checker.Add("kafka", health.Kafka(kafkaClient.Dialer, kafkaClient.Brokers))
+	context.AfterFunc(ctx, checker.Drain)
+
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	metrics.Register(mux)
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.HTTPPort),
+		Handler: mux,
+	}
+	if cfg.TLS.Enabled() {
+		certs, err := tlsconfig.Start(ctx, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, cfg.TLS.ReloadInterval)
+		if err != nil {
+			logging.Fatal("Failed to load TLS certificates", "error", err)
+		}
+		if httpServer.TLSConfig, err = certs.ServerConfig(false); err != nil {
+			logging.Fatal("Failed to configure TLS", "error", err)
+		}
+	}
+	go func() {
+		slog.Info("Health endpoints listening", "addr", httpServer.Addr, "tls", httpServer.TLSConfig != nil)
+		var err error
+		if httpServer.TLSConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatal("HTTP server failed", "error", err)
+		}
+	}()
+	defer httpServer.Close()
+
+	// Deleted and hidden messages are removed for good after MESSAGE_RETENTION
+	if cfg.Purge.Interval > 0 {
+		go runPurge(ctx, messages, cfg.Purge)
+	}
+
+	slog.Info("Persistence service started", "topic", cfg.Kafka.Topic, "group", kafkaGroupID)
+
+	consume(ctx, workCtx, reader, cfg.Kafka, func(ctx context.Context, msg kafka.Message) error {
+		return processAndPersist(ctx, messages, publisher, kafkaGroupID, msg)
+	})
+}
+
+// runMigrate runs a migrate subcommand against the configured database
+func runMigrate(cfg config.Database, args []string) {
+	db := store.OpenDB(cfg)
+	defer db.Close()
+
+	if err := migrations.RunCommand(context.Background(), db, args); err != nil {
+		logging.Fatal("Migration failed", "error", err)
+	}
+}
+
+// processAndPersist handles the complete message processing pipeline. Its span
+// continues the trace started by the sender's WebSocket frame.
+func processAndPersist(ctx context.Context, messages store.MessageStore, publisher *events.Publisher, group string, msg kafka.Message) (err error) {
+	ctx, span := tracer.Start(tracing.Extract(ctx, msg), "persist.message",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(tracing.ConsumerAttributes(group, msg)...),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "persist failed")
+		}
+		span.End()
+	}()
+
+	event, err := events.Decode(msg.Value)
+	if err != nil {
+		reason := reasonInvalidMessage
+		if errors.Is(err, events.ErrUnsupportedVersion) {
+			reason = reasonUnsupportedVersion
+		}
+		persistFailures.WithLabelValues(reason).Inc()
+		return fmt.Errorf("%w: %w", errPermanent, err)
+	}
+
+	// Only sent messages are stored here; auth-service stores edits and
+	// deletions before publishing them, unread changes are this service's
+	// own, and event types from newer producers are skipped
+	sent := event.GetMessageSent()
+	if sent == nil {
+		slog.DebugContext(ctx, "Skipping event without a known payload", "event_id", event.EventId)
+		return nil
+	}
+	if sent.Sender == "" || sent.Recipient == "" {
+		persistFailures.WithLabelValues(reasonInvalidMessage).Inc()
+		return fmt.Errorf("%w: event %s has no sender or recipient", errPermanent, event.EventId)
+	}
+	span.SetAttributes(attribute.String("chat.event_id", event.EventId))
+
+	// Whether the sender may message the recipient was decided once, by
+	// ws-service before publishing; the recipient may already have seen the
+	// message, so it is stored even if a block was added since. A missing
+	// user means the account was removed, and its messages with it
+	start := time.Now()
+	id, err := createMessage(ctx, messages, event.EventId, sent)
+	insertDuration.WithLabelValues(metrics.Result(err)).Observe(time.Since(start).Seconds())
+	if errors.Is(err, store.ErrUserNotFound) {
+		persistFailures.WithLabelValues(reasonInvalidMessage).Inc()
+		return fmt.Errorf("%w: event %s: %w", errPermanent, event.EventId, err)
+	}
+	if err != nil {
+		persistFailures.WithLabelValues(reasonInsert).Inc()
+		return fmt.Errorf("error creating message: %w", err)
+	}
+	span.SetAttributes(attribute.Int("chat.message_id", id))
+
+	slog.DebugContext(ctx, "Persisted message", "message_id", id, "event_id", event.EventId, "from", sent.Sender, "to", sent.Recipient)
+
+	publishUnread(ctx, messages, publisher, id, sent)
+	return nil
+}
+
+// errPermanent marks failures that retrying cannot fix, such as undecodable
+// events or events naming removed users; such messages are skipped
+var errPermanent = errors.New("message cannot be persisted")
+
+// persistWithRetry calls persist until it succeeds, fails permanently or ctx
+// is done, doubling the delay between attempts from minDelay up to maxDelay
+func persistWithRetry(ctx context.Context, minDelay, maxDelay time.Duration, persist func(context.Context) error) error {
+	delay := minDelay
+	for {
+		err := persist(ctx)
+		if err == nil || errors.Is(err, errPermanent) || ctx.Err() != nil {
+			return err
+		}
+
+		slog.WarnContext(ctx, "Error persisting message, retrying", "retry_in", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+// createMessage inserts the row inside a client span for the database call
+func createMessage(ctx context.Context, messages store.MessageStore, eventID string, sent *eventspb.MessageSent) (int, error) {
+	ctx, span := tracer.Start(ctx, "INSERT messages",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", "INSERT"),
+			attribute.String("db.collection.name", "messages"),
+		),
+	)
+	defer span.End()
+
+	// Events from producers that left the content type out are plain text
+	contentType := sent.ContentType
+	if contentType == "" {
+		contentType = events.ContentTypeText
+	}
+	id, err := messages.CreateMessage(ctx, eventID, sent.Sender, sent.Recipient, contentType, sent.Content, sent.Metadata)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "insert failed")
+	}
+	return id, err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Failure reasons recorded by persistFailures
+const (
+	reasonInvalidMessage     = "invalid_message"
+	reasonUnsupportedVersion = "unsupported_version"
+	reasonInsert             = "insert"
+	reasonCommit             = "commit"
+)
+
+// Persistence metrics, served on /metrics alongside the shared Kafka metrics
+var (
+	insertDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "persistence_insert_duration_seconds",
+		Help:    "Time taken to insert a message into Postgres.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"result"})
+
+	persistFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "persistence_failures_total",
+		Help: "Messages that could not be persisted, by reason.",
+	}, []string{"reason"})
+
+	purgeRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "persistence_purge_runs_total",
+		Help: "Runs of the message purge job, by result.",
+	}, []string{"result"})
+
+	purgedMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "persistence_purged_messages_total",
+		Help: "Deleted or hidden messages permanently removed after the retention period.",
+	})
+)
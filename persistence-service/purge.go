@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/store"
+)
+
+// runPurge purges messages every cfg.Interval until ctx is cancelled
+func runPurge(ctx context.Context, messages store.MessageStore, cfg PurgeConfig) {
+	slog.Info("Message purge scheduled", "interval", cfg.Interval, "retention", cfg.Retention)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := purge(ctx, messages, time.Now().Add(-cfg.Retention), cfg.BatchSize)
+			purgeRuns.WithLabelValues(metrics.Result(err)).Inc()
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Error purging messages", "purged", purged, "error", err)
+			} else if purged > 0 {
+				slog.Info("Purged messages", "purged", purged)
+			}
+		}
+	}
+}
+
+// purge removes messages past the cutoff in batches of batchSize, so no
+// single statement holds locks for long, and returns how many it removed
+func purge(ctx context.Context, messages store.MessageStore, cutoff time.Time, batchSize int) (int, error) {
+	total := 0
+	for {
+		n, err := messages.PurgeMessages(ctx, cutoff, batchSize)
+		total += n
+		purgedMessages.Add(float64(n))
+		if err != nil || n < batchSize || ctx.Err() != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RishangS/shared/store"
+)
+
+// recordingStore answers PurgeMessages with the queued counts, recording
+// the cutoff and limit of each call
+type recordingStore struct {
+	store.MessageStore
+	mu      sync.Mutex
+	counts  []int
+	err     error
+	cutoffs []time.Time
+	limits  []int
+}
+
+func (s *recordingStore) PurgeMessages(_ context.Context, cutoff time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutoffs = append(s.cutoffs, cutoff)
+	s.limits = append(s.limits, limit)
+	if len(s.counts) == 0 {
+		return 0, s.err
+	}
+	n := s.counts[0]
+	s.counts = s.counts[1:]
+	return n, nil
+}
+
+// TestPurgeBatches checks that purge repeats full batches and stops after
+// a short one or an error
+func TestPurgeBatches(t *testing.T) {
+	messages := &recordingStore{counts: []int{2, 2, 1, 2}}
+	cutoff := time.Now().Add(-time.Hour)
+	n, err := purge(context.Background(), messages, cutoff, 2)
+	if n != 5 || err != nil {
+		t.Fatalf("got %d, %v, want 5 purged", n, err)
+	}
+	if len(messages.limits) != 3 {
+		t.Errorf("made %d calls, want 3", len(messages.limits))
+	}
+	for i := range messages.limits {
+		if messages.limits[i] != 2 || !messages.cutoffs[i].Equal(cutoff) {
+			t.Errorf("call %d got limit %d and cutoff %s", i, messages.limits[i], messages.cutoffs[i])
+		}
+	}
+
+	failing := &recordingStore{counts: []int{2}, err: errors.New("connection reset")}
+	if n, err := purge(context.Background(), failing, cutoff, 2); n != 2 || err == nil {
+		t.Errorf("got %d, %v, want 2 purged and the error", n, err)
+	}
+}
+
+// TestRunPurgeCutoff checks that each run purges messages older than the
+// retention period
+func TestRunPurgeCutoff(t *testing.T) {
+	messages := &recordingStore{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runPurge(ctx, messages, PurgeConfig{Interval: 5 * time.Millisecond, Retention: 24 * time.Hour, BatchSize: 10})
+	}()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		messages.mu.Lock()
+		calls := len(messages.cutoffs)
+		messages.mu.Unlock()
+		if calls > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no purge ran")
+		}
+	}
+	cancel()
+	<-done
+	after := time.Now()
+
+	cutoff := messages.cutoffs[0]
+	if cutoff.Before(before.Add(-24*time.Hour)) || cutoff.After(after.Add(-24*time.Hour)) {
+		t.Errorf("cutoff %s is not 24h before the run", cutoff)
+	}
+}
+
+// TestPurgeRemovesOnlyExpired checks against the memory store that only
+// tombstones deleted before the cutoff are removed
+func TestPurgeRemovesOnlyExpired(t *testing.T) {
+	ctx := context.Background()
+	users := store.NewMemoryUserStore()
+	alice, err := users.CreateUser(ctx, "alice", "Correct-horse7", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.CreateUser(ctx, "bob", "Correct-horse7", "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	messages := store.NewMemoryMessageStore(users)
+
+	var ids []int
+	for i := 0; i < 5; i++ {
+		id, err := messages.CreateMessage(ctx, "", "alice", "bob", "", "hi", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids[:3] {
+		if err := messages.DeleteMessage(ctx, id, alice.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Deleted within the retention period: kept
+	if n, err := purge(ctx, messages, time.Now().Add(-time.Hour), 2); n != 0 || err != nil {
+		t.Fatalf("got %d, %v, want nothing purged before the cutoff", n, err)
+	}
+	if n, err := purge(ctx, messages, time.Now().Add(time.Second), 2); n != 3 || err != nil {
+		t.Fatalf("got %d, %v, want the 3 tombstones purged", n, err)
+	}
+	for i, id := range ids {
+		_, err := messages.GetMessage(ctx, id)
+		if purged := errors.Is(err, store.ErrMessageNotFound); purged != (i < 3) {
+			t.Errorf("message %d: purged %v, want %v", i, purged, i < 3)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	"github.com/RishangS/shared/store"
+)
+
+// publishUnread publishes the recipient's new unread counts once message id
+// from sent.Sender is stored, keyed by the recipient like the reads that
+// lower them, so that their live sessions can update their badges. The
+// message itself is already stored, so failures are only logged.
+func publishUnread(ctx context.Context, messages store.MessageStore, publisher *events.Publisher, id int, sent *eventspb.MessageSent) {
+	msg, err := messages.GetMessage(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error looking up stored message", "message_id", id, "error", err)
+		return
+	}
+	counts, err := messages.GetUnreadCounts(ctx, msg.RecipientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting unread messages", "user_id", msg.RecipientID, "error", err)
+		return
+	}
+
+	changed := &eventspb.UnreadChanged{User: sent.Recipient, Peer: sent.Sender}
+	for _, c := range counts {
+		if c.PeerID == msg.SenderID {
+			changed.UnreadCount = int64(c.Count)
+		}
+		changed.TotalUnread += int64(c.Count)
+	}
+	if err := publisher.Publish(ctx, sent.Recipient, events.NewUnreadChanged(changed)); err != nil {
+		slog.ErrorContext(ctx, "Error publishing unread counts", "user_id", msg.RecipientID, "error", err)
+	}
+}
@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Database configures the PostgreSQL connection pool
+type Database struct {
+	Host         string        `yaml:"host" env:"DB_HOST" required:"true"`
+	Port         int           `yaml:"port" env:"DB_PORT" default:"5432"`
+	Name         string        `yaml:"name" env:"DB_NAME" required:"true"`
+	User         string        `yaml:"user" env:"DB_USER" required:"true"`
+	Password     string        `yaml:"password" env:"DB_PASSWORD" required:"true" secret:"true"`
+	SSLMode      string        `yaml:"sslmode" env:"DB_SSLMODE" default:"disable"`
+	SSLRootCert  string        `yaml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert      string        `yaml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey       string        `yaml:"sslkey" env:"DB_SSLKEY"`
+	MaxOpenConns int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"10"`
+	MaxIdleConns int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"5"`
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" default:"5s"`
+}
+
+// DSN returns the connection string, which contains the password and must
+// not be logged. The driver rereads the certificate files on every new
+// connection, so rotated files apply without a restart.
+func (d Database) DSN() string {
+	query := url.Values{"sslmode": {d.SSLMode}}
+	for key, value := range map[string]string{"sslrootcert": d.SSLRootCert, "sslcert": d.SSLCert, "sslkey": d.SSLKey} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+		Path:     d.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Validate checks the pool settings
+func (d *Database) Validate() []string {
+	problems := ValidPort("DB_PORT", d.Port)
+	if d.MaxIdleConns > d.MaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
+	}
+	if d.QueryTimeout <= 0 {
+		problems = append(problems, "DB_QUERY_TIMEOUT must be positive")
+	}
+	switch d.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		problems = append(problems, fmt.Sprintf("DB_SSLMODE must be a libpq sslmode, got %q", d.SSLMode))
+	}
+	if (d.SSLCert == "") != (d.SSLKey == "") {
+		problems = append(problems, "DB_SSLCERT and DB_SSLKEY must be set together")
+	}
+	if strings.HasPrefix(d.SSLMode, "verify-") && d.SSLRootCert == "" {
+		problems = append(problems, "DB_SSLROOTCERT is required when DB_SSLMODE is "+d.SSLMode)
+	}
+	return problems
+}
+
+// Kafka configures the brokers shared by producers and consumers and how to
+// authenticate to them
+type Kafka struct {
+	Brokers []string      `yaml:"brokers" env:"KAFKA_BROKERS" required:"true"`
+	TLS     KafkaTLS      `yaml:"tls"`
+	SASL    KafkaSASL     `yaml:"sasl"`
+	Timeout time.Duration `yaml:"dial_timeout" env:"KAFKA_DIAL_TIMEOUT" default:"10s"`
+
+	// CreateTopics creates missing topics at startup; existing ones are
+	// validated either way
+	CreateTopics bool `yaml:"create_topics" env:"KAFKA_CREATE_TOPICS" default:"true"`
+}
+
+// Validate checks the dial timeout
+func (k *Kafka) Validate() []string {
+	if k.Timeout <= 0 {
+		return []string{"KAFKA_DIAL_TIMEOUT must be positive"}
+	}
+	return nil
+}
+
+// KafkaTLS encrypts broker connections; the files are reloaded when they change
+type KafkaTLS struct {
+	Enabled    bool   `yaml:"enabled" env:"KAFKA_TLS_ENABLED"`
+	CAFile     string `yaml:"ca_file" env:"KAFKA_TLS_CA_FILE"`
+	CertFile   string `yaml:"cert_file" env:"KAFKA_TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"KAFKA_TLS_KEY_FILE"`
+	ServerName string `yaml:"server_name" env:"KAFKA_TLS_SERVER_NAME"`
+}
+
+// Validate checks that the client certificate and key come together
+func (t *KafkaTLS) Validate() []string {
+	var problems []string
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "KAFKA_TLS_CERT_FILE and KAFKA_TLS_KEY_FILE must be set together")
+	}
+	if !t.Enabled && (t.CAFile != "" || t.CertFile != "") {
+		problems = append(problems, "KAFKA_TLS_* files are set but KAFKA_TLS_ENABLED is false")
+	}
+	return problems
+}
+
+// KafkaSASL authenticates to the brokers; an empty mechanism disables SASL
+type KafkaSASL struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM"`
+	Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD" secret:"true"`
+}
+
+// Validate checks the mechanism name and that credentials are present
+func (s *KafkaSASL) Validate() []string {
+	switch s.Mechanism {
+	case "":
+		return nil
+	case "plain", "scram-sha-256", "scram-sha-512":
+	default:
+		return []string{fmt.Sprintf("KAFKA_SASL_MECHANISM must be plain, scram-sha-256 or scram-sha-512, got %q", s.Mechanism)}
+	}
+	if s.Username == "" || s.Password == "" {
+		return []string{"KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD are required with KAFKA_SASL_MECHANISM"}
+	}
+	return nil
+}
+
+// Topic describes the layout expected for one topic. Like Producer it is
+// nested with an env_prefix, e.g. KAFKA_MESSAGES_PARTITIONS. A zero retention
+// leaves the broker default in place and is not checked.
+type Topic struct {
+	Partitions        int           `yaml:"partitions" env:"PARTITIONS" default:"1"`
+	ReplicationFactor int           `yaml:"replication_factor" env:"REPLICATION_FACTOR" default:"1"`
+	Retention         time.Duration `yaml:"retention" env:"RETENTION"`
+}
+
+// Validate checks that the layout is possible
+func (t *Topic) Validate() []string {
+	var problems []string
+	if t.Partitions < 1 || t.ReplicationFactor < 1 {
+		problems = append(problems, "topic partitions and replication_factor must be at least 1")
+	}
+	if t.Retention < 0 {
+		problems = append(problems, "topic retention must not be negative")
+	}
+	return problems
+}
+
+// Producer tunes a writer for one topic. It is meant to be nested with an
+// env_prefix, e.g. KAFKA_MESSAGES_ gives KAFKA_MESSAGES_COMPRESSION.
+type Producer struct {
+	Compression  string        `yaml:"compression" env:"COMPRESSION" default:"none"`
+	RequiredAcks string        `yaml:"required_acks" env:"REQUIRED_ACKS" default:"all"`
+	BatchSize    int           `yaml:"batch_size" env:"BATCH_SIZE" default:"100"`
+	BatchBytes   int64         `yaml:"batch_bytes" env:"BATCH_BYTES" default:"1048576"`
+	BatchTimeout time.Duration `yaml:"batch_timeout" env:"BATCH_TIMEOUT" default:"10ms"`
+}
+
+// Validate checks the codec and acknowledgement names and the batch limits
+func (p *Producer) Validate() []string {
+	var problems []string
+	switch p.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		problems = append(problems, fmt.Sprintf("producer compression must be none, gzip, snappy, lz4 or zstd, got %q", p.Compression))
+	}
+	switch p.RequiredAcks {
+	case "none", "one", "all":
+	default:
+		problems = append(problems, fmt.Sprintf("producer required_acks must be none, one or all, got %q", p.RequiredAcks))
+	}
+	if p.BatchSize < 1 || p.BatchBytes < 1 || p.BatchTimeout <= 0 {
+		problems = append(problems, "producer batch_size, batch_bytes and batch_timeout must be positive")
+	}
+	return problems
+}
+
+// TLS configures the certificate served by a service's listeners. TLS is
+// enabled when a certificate is set; the files are reloaded when they change.
+type TLS struct {
+	CertFile       string        `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile        string        `yaml:"key_file" env:"TLS_KEY_FILE"`
+	CAFile         string        `yaml:"ca_file" env:"TLS_CA_FILE"`
+	ClientAuth     bool          `yaml:"client_auth" env:"TLS_CLIENT_AUTH"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"30s"`
+}
+
+// Enabled reports whether the listeners should serve TLS
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Validate checks that the files come in usable combinations
+func (t *TLS) Validate() []string {
+	var problems []string
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if t.ClientAuth && (t.CAFile == "" || !t.Enabled()) {
+		problems = append(problems, "TLS_CLIENT_AUTH requires TLS_CERT_FILE and TLS_CA_FILE")
+	}
+	if t.ReloadInterval <= 0 {
+		problems = append(problems, "TLS_RELOAD_INTERVAL must be positive")
+	}
+	return problems
+}
+
+// CORS lets browser pages on the allowed origins call a service's HTTP API.
+// Origins are exact, like https://chat.example.com, or cover subdomains,
+// like https://*.example.com; "*" allows any origin.
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,X-Request-Id"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" default:"10m"`
+}
+
+// Validate checks the origins and that "*" is not combined with credentials
+func (c *CORS) Validate() []string {
+	problems := ValidOrigins("CORS_ALLOWED_ORIGINS", c.AllowedOrigins)
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		problems = append(problems, `CORS_ALLOW_CREDENTIALS cannot be used with the "*" origin`)
+	}
+	if c.MaxAge < 0 {
+		problems = append(problems, "CORS_MAX_AGE must not be negative")
+	}
+	return problems
+}
+
+// Log configures the structured logger
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info"`
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json"`
+}
+
+// Validate checks the level and format names
+func (l *Log) Validate() []string {
+	var problems []string
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL must be debug, info, warn or error, got %q", l.Level))
+	}
+	switch l.Format {
+	case "json", "text":
+	default:
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT must be json or text, got %q", l.Format))
+	}
+	return problems
+}
+
+// Lifecycle configures probes and shutdown
+type Lifecycle struct {
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"20s"`
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+}
+
+// Validate checks that both timeouts are positive
+func (l *Lifecycle) Validate() []string {
+	var problems []string
+	if l.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+	if l.HealthCheckTimeout <= 0 {
+		problems = append(problems, "HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	return problems
+}
+
+// ValidPort reports a problem if port is outside the TCP range
+func ValidPort(env string, port int) []string {
+	if port < 1 || port > 65535 {
+		return []string{fmt.Sprintf("%s must be between 1 and 65535, got %d", env, port)}
+	}
+	return nil
+}
+
+// ValidOrigins reports origins that are neither "*" nor a scheme and host,
+// optionally with a port and a leading "*." wildcard label
+func ValidOrigins(env string, origins []string) []string {
+	var problems []string
+	for _, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			problems = append(problems, fmt.Sprintf("%s entry %q must look like https://example.com or https://*.example.com", env, origin))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable pointing at the optional YAML file
+const FileEnv = "CONFIG_FILE"
+
+// Redacted replaces secret values when the configuration is printed
+const Redacted = "[REDACTED]"
+
+// Validator is implemented by configuration structs with cross-field rules.
+// It returns one description per problem found.
+type Validator interface {
+	Validate() []string
+}
+
+// Error aggregates every problem found while loading the configuration, so
+// that all of them can be fixed at once
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load fills cfg, a pointer to a struct, in increasing order of precedence from
+// the `default` tags, the YAML file named by CONFIG_FILE (keys follow the `yaml`
+// tags), the variables named by the `env` tags and, for each of those, a file
+// named by the same variable with a _FILE suffix (for mounted secrets).
+// A nested struct tagged `env_prefix:"X_"` prefixes the env names of its
+// fields, so one block type can be reused, e.g. once per Kafka topic.
+// Fields tagged `required:"true"` must end up non-zero. All problems are
+// returned together as an *Error.
+func Load(cfg any) error {
+	root := reflect.ValueOf(cfg)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Load requires a pointer to a struct")
+	}
+
+	var problems []string
+
+	walk(root.Elem(), "", "", func(f field) {
+		if def, ok := f.tag.Lookup("default"); ok {
+			if err := set(f.value, def); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid default %q: %v", f.name(), def, err))
+			}
+		}
+	})
+
+	if path := os.Getenv(FileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return &Error{Problems: []string{fmt.Sprintf("reading %s: %v", path, err)}}
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return &Error{Problems: []string{fmt.Sprintf("parsing %s: %v", path, err)}}
+		}
+	}
+
+	walk(root.Elem(), "", "", func(f field) {
+		env := f.env
+		if env == "" {
+			return
+		}
+
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if err := set(f.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid value %q: %v", env, value, err))
+			}
+		}
+
+		if path := os.Getenv(env + "_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s_FILE: %v", env, err))
+				return
+			}
+			value := strings.TrimRight(string(data), "\r\n")
+			if err := set(f.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s_FILE: invalid value: %v", env, err))
+			}
+		}
+	})
+
+	walk(root.Elem(), "", "", func(f field) {
+		if f.tag.Get("required") == "true" && f.value.IsZero() {
+			problems = append(problems, fmt.Sprintf("%s is required", f.name()))
+		}
+	})
+
+	if len(problems) == 0 {
+		problems = append(problems, validate(root.Elem())...)
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// Redact returns the effective configuration as nested maps keyed by the
+// `yaml` tags, with fields tagged `secret:"true"` replaced by Redacted
+func Redact(cfg any) map[string]any {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return redact(v)
+}
+
+func redact(v reflect.Value) map[string]any {
+	out := make(map[string]any)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if isInline(sf) {
+			for k, val := range redact(fv) {
+				out[k] = val
+			}
+			continue
+		}
+		key := yamlKey(sf)
+
+		switch {
+		case sf.Tag.Get("secret") == "true":
+			if fv.IsZero() {
+				out[key] = ""
+			} else {
+				out[key] = Redacted
+			}
+		case isNested(fv):
+			out[key] = redact(fv)
+		case fv.Type() == durationType:
+			out[key] = fv.Interface().(time.Duration).String()
+		default:
+			out[key] = fv.Interface()
+		}
+	}
+	return out
+}
+
+// field is a leaf configuration value found while walking a struct
+type field struct {
+	path  string
+	env   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+// name identifies the field in error messages by its env variable and YAML path
+func (f field) name() string {
+	if f.env != "" {
+		return fmt.Sprintf("%s (%s)", f.env, f.path)
+	}
+	return f.path
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk calls fn for every exported leaf field, descending into nested structs
+// and accumulating their env_prefix tags
+func walk(v reflect.Value, prefix, envPrefix string, fn func(f field)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if isInline(sf) {
+			walk(fv, prefix, envPrefix, fn)
+			continue
+		}
+
+		path := yamlKey(sf)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if isNested(fv) {
+			walk(fv, path, envPrefix+sf.Tag.Get("env_prefix"), fn)
+			continue
+		}
+
+		env := sf.Tag.Get("env")
+		if env != "" {
+			env = envPrefix + env
+		}
+		fn(field{path: path, env: env, tag: sf.Tag, value: fv})
+	}
+}
+
+// validate runs Validate on v and on every nested struct implementing Validator
+func validate(v reflect.Value) []string {
+	var problems []string
+	for i := 0; i < v.NumField(); i++ {
+		if fv := v.Field(i); v.Type().Field(i).IsExported() && isNested(fv) {
+			problems = append(problems, validate(fv)...)
+		}
+	}
+	if validator, ok := v.Addr().Interface().(Validator); ok {
+		problems = append(problems, validator.Validate()...)
+	}
+	return problems
+}
+
+func isNested(v reflect.Value) bool {
+	return v.Kind() == reflect.Struct && v.Type() != durationType
+}
+
+// isInline reports whether an embedded struct's fields belong to its parent
+func isInline(sf reflect.StructField) bool {
+	return sf.Anonymous && sf.Type.Kind() == reflect.Struct &&
+		(sf.Tag.Get("yaml") == "" || strings.Contains(sf.Tag.Get("yaml"), ",inline"))
+}
+
+func yamlKey(sf reflect.StructField) string {
+	if tag := sf.Tag.Get("yaml"); tag != "" && tag != "-" {
+		return strings.Split(tag, ",")[0]
+	}
+	return strings.ToLower(sf.Name)
+}
+
+// set parses s into v according to v's type
+func set(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testTopic is a block reused under two env prefixes
+type testTopic struct {
+	Partitions int           `yaml:"partitions" env:"PARTITIONS" default:"1"`
+	Timeout    time.Duration `yaml:"timeout" env:"TIMEOUT" default:"5s"`
+}
+
+func (t *testTopic) Validate() []string {
+	if t.Partitions < 1 {
+		return []string{"PARTITIONS must be at least 1"}
+	}
+	return nil
+}
+
+type testConfig struct {
+	Name      string    `yaml:"name" env:"TEST_NAME" default:"default"`
+	Level     string    `yaml:"level" env:"TEST_LEVEL" default:"info"`
+	Count     int       `yaml:"count" env:"TEST_COUNT" default:"1"`
+	Token     string    `yaml:"token" env:"TEST_TOKEN" required:"true" secret:"true"`
+	Optional  string    `yaml:"optional" env:"TEST_OPTIONAL" secret:"true"`
+	Primary   testTopic `yaml:"primary" env_prefix:"TEST_PRIMARY_"`
+	Secondary testTopic `yaml:"secondary" env_prefix:"TEST_SECONDARY_"`
+	Lifecycle Lifecycle `yaml:"lifecycle"`
+}
+
+// setEnv clears every variable testConfig reads and then sets env, so the
+// tests do not depend on the environment they run in
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{
+		FileEnv, "TEST_NAME", "TEST_LEVEL", "TEST_COUNT", "TEST_TOKEN", "TEST_TOKEN_FILE", "TEST_OPTIONAL",
+		"TEST_PRIMARY_PARTITIONS", "TEST_SECONDARY_PARTITIONS", "SHUTDOWN_TIMEOUT", "HEALTH_CHECK_TIMEOUT",
+	} {
+		t.Setenv(name, "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+// writeFile writes data to a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// problems returns the problems of a Load error, failing if it is not an *Error
+func problems(t *testing.T, err error) []string {
+	t.Helper()
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	return cfgErr.Problems
+}
+
+// TestLoadPrecedence checks that YAML overrides defaults and env overrides YAML
+func TestLoadPrecedence(t *testing.T) {
+	setEnv(t, map[string]string{
+		FileEnv:      writeFile(t, "config.yaml", "name: from-yaml\nlevel: debug\nprimary:\n  timeout: 7s\n"),
+		"TEST_LEVEL": "warn",
+		"TEST_TOKEN": "token",
+	})
+
+	var cfg testConfig
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Count != 1 {
+		t.Errorf("count: got %d, want the default 1", cfg.Count)
+	}
+	if cfg.Name != "from-yaml" || cfg.Primary.Timeout != 7*time.Second {
+		t.Errorf("name and timeout: got %q and %s, want the YAML values", cfg.Name, cfg.Primary.Timeout)
+	}
+	if cfg.Level != "warn" {
+		t.Errorf("level: got %q, want the env value warn", cfg.Level)
+	}
+}
+
+// TestLoadFileSecrets checks that a _FILE variable supplies a value, trimmed
+// of its trailing newline, and that an unreadable file is reported
+func TestLoadFileSecrets(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_TOKEN_FILE": writeFile(t, "token", "from-file\n")})
+	var cfg testConfig
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "from-file" {
+		t.Errorf("got token %q, want from-file", cfg.Token)
+	}
+
+	setEnv(t, map[string]string{"TEST_TOKEN_FILE": filepath.Join(t.TempDir(), "missing")})
+	got := problems(t, Load(&testConfig{}))
+	if len(got) != 2 {
+		t.Errorf("got problems %q, want the unreadable file and the missing token", got)
+	}
+}
+
+// TestLoadEnvPrefix checks that env_prefix keeps the two uses of a block apart
+func TestLoadEnvPrefix(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_TOKEN": "token", "TEST_PRIMARY_PARTITIONS": "3"})
+	var cfg testConfig
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Primary.Partitions != 3 || cfg.Secondary.Partitions != 1 {
+		t.Errorf("got partitions %d and %d, want 3 and 1", cfg.Primary.Partitions, cfg.Secondary.Partitions)
+	}
+}
+
+// TestLoadAggregatesProblems checks that every problem is reported at once:
+// parse and required errors first, then the Validate rules of every block
+func TestLoadAggregatesProblems(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_COUNT": "many"})
+	got := problems(t, Load(&testConfig{}))
+	want := []string{
+		`TEST_COUNT: invalid value "many": strconv.ParseInt: parsing "many": invalid syntax`,
+		"TEST_TOKEN (token) is required",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got problems %q, want %q", got, want)
+	}
+
+	setEnv(t, map[string]string{
+		"TEST_TOKEN":                "token",
+		"TEST_SECONDARY_PARTITIONS": "0",
+		"SHUTDOWN_TIMEOUT":          "0s",
+		"HEALTH_CHECK_TIMEOUT":      "-1s",
+	})
+	got = problems(t, Load(&testConfig{}))
+	want = []string{
+		"PARTITIONS must be at least 1",
+		"SHUTDOWN_TIMEOUT must be positive",
+		"HEALTH_CHECK_TIMEOUT must be positive",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got problems %q, want %q", got, want)
+	}
+}
+
+// TestRedact checks that set secrets are replaced, unset ones stay empty and
+// nested blocks and durations are rendered
+func TestRedact(t *testing.T) {
+	cfg := testConfig{Name: "chat", Token: "token", Primary: testTopic{Partitions: 3, Timeout: time.Second}}
+	got := Redact(&cfg)
+
+	if got["token"] != Redacted {
+		t.Errorf("token: got %v, want %s", got["token"], Redacted)
+	}
+	if got["optional"] != "" {
+		t.Errorf("optional: got %v, want empty", got["optional"])
+	}
+	if got["name"] != "chat" {
+		t.Errorf("name: got %v, want chat", got["name"])
+	}
+	primary, ok := got["primary"].(map[string]any)
+	if !ok || primary["partitions"] != 3 || primary["timeout"] != "1s" {
+		t.Errorf("primary: got %v, want partitions 3 and timeout 1s", got["primary"])
+	}
+}
@@ -0,0 +1,112 @@
+// Package cors checks request origins against an allowlist, for WebSocket
+// upgrades and for browser calls to the HTTP gateway
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RishangS/shared/config"
+)
+
+// Origins is an allowlist of origins in the format checked by
+// config.ValidOrigins
+type Origins struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []string // "scheme://.suffix" for each "scheme://*.suffix"
+}
+
+// NewOrigins builds an allowlist from validated patterns
+func NewOrigins(patterns []string) *Origins {
+	o := &Origins{exact: make(map[string]bool)}
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		switch {
+		case p == "*":
+			o.any = true
+		case strings.Contains(p, "://*."):
+			o.wildcards = append(o.wildcards, strings.Replace(p, "://*.", "://.", 1))
+		default:
+			o.exact[p] = true
+		}
+	}
+	return o
+}
+
+// Allowed reports whether origin, the value of an Origin header, is on the list.
+// A wildcard covers subdomains at any depth but not the domain itself.
+func (o *Origins) Allowed(origin string) bool {
+	if o.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if o.exact[origin] {
+		return true
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || host == "" {
+		return false
+	}
+	for _, w := range o.wildcards {
+		wScheme, suffix, _ := strings.Cut(w, "://")
+		if scheme == wScheme && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameHost reports whether origin names the host r was sent to, as the
+// browser's own page would
+func SameHost(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Middleware answers preflight requests and adds CORS headers for allowed
+// origins. Requests from other origins are passed on without the headers, so
+// browsers do not expose the responses to the calling page; their preflight
+// requests are refused.
+func Middleware(cfg config.CORS, next http.Handler) http.Handler {
+	origins := NewOrigins(cfg.AllowedOrigins)
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !origins.Allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,109 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RishangS/shared/config"
+)
+
+// TestOriginsAllowed checks exact, wildcard and any-origin matching
+func TestOriginsAllowed(t *testing.T) {
+	origins := NewOrigins([]string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"})
+	for _, tc := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://App.Example.com", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://evil-app.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		// A wildcard covers subdomains only, and only on whole labels
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"http://a.example.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"null", false},
+		{"", false},
+	} {
+		if got := origins.Allowed(tc.origin); got != tc.ok {
+			t.Errorf("%q: got %v, want %v", tc.origin, got, tc.ok)
+		}
+	}
+
+	if !NewOrigins([]string{"*"}).Allowed("https://anything.test") {
+		t.Error("* did not allow every origin")
+	}
+	if NewOrigins(nil).Allowed("https://app.example.com") {
+		t.Error("an empty list allowed an origin")
+	}
+}
+
+// TestSameHost checks that only the host the request was sent to matches
+func TestSameHost(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://chat.example.com/ws", nil)
+	if !SameHost("https://chat.example.com", r) {
+		t.Error("own host did not match")
+	}
+	if SameHost("https://other.example.com", r) {
+		t.Error("other host matched")
+	}
+}
+
+// TestMiddleware checks the headers for allowed and refused origins, for
+// simple and preflight requests
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(config.CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/v1/contacts", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "https://app.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("allowed request: got %d with headers %v", w.Code, w.Header())
+	}
+
+	w = serve(http.MethodOptions, "https://app.example.com", true)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+		w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("allowed preflight: got %d with headers %v", w.Code, w.Header())
+	}
+
+	// Other origins reach the handler without CORS headers, and their
+	// preflight requests are refused
+	w = serve(http.MethodGet, "https://evil.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: got %d with headers %v", w.Code, w.Header())
+	}
+	if w = serve(http.MethodOptions, "https://evil.example.com", true); w.Code != http.StatusForbidden {
+		t.Errorf("other origin preflight: got %d, want 403", w.Code)
+	}
+
+	if w = serve(http.MethodGet, "", false); w.Code != http.StatusOK || w.Header().Get("Vary") != "" {
+		t.Errorf("request without Origin: got %d with headers %v", w.Code, w.Header())
+	}
+}
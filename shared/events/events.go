@@ -0,0 +1,107 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+
+	eventspb "github.com/RishangS/shared/gen/events"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Schema version written by this code. Adding fields bumps MinorVersion;
+// changing the meaning of existing ones bumps MajorVersion.
+const (
+	MajorVersion = 1
+	MinorVersion = 3
+)
+
+// ContentTypeHeader names the Kafka header describing the record value
+const ContentTypeHeader = "content-type"
+
+// ContentType identifies a protobuf-encoded ChatEvent
+const ContentType = "application/x-protobuf; messageType=chat.events.ChatEvent"
+
+// ContentTypeText is the content type of plain text messages
+const ContentTypeText = "text/plain"
+
+// ErrUnsupportedVersion is returned for events of an unknown major version
+var ErrUnsupportedVersion = errors.New("unsupported event schema version")
+
+// NewMessageSent wraps msg in an event stamped with the current schema
+// version, a new event ID and the current time
+func NewMessageSent(msg *eventspb.MessageSent) *eventspb.ChatEvent {
+	event := newEvent()
+	event.Payload = &eventspb.ChatEvent_MessageSent{MessageSent: msg}
+	return event
+}
+
+// NewMessageEdited wraps msg like NewMessageSent. Added in schema 1.1.
+func NewMessageEdited(msg *eventspb.MessageEdited) *eventspb.ChatEvent {
+	event := newEvent()
+	event.Payload = &eventspb.ChatEvent_MessageEdited{MessageEdited: msg}
+	return event
+}
+
+// NewMessageDeleted wraps msg like NewMessageSent. Added in schema 1.1.
+func NewMessageDeleted(msg *eventspb.MessageDeleted) *eventspb.ChatEvent {
+	event := newEvent()
+	event.Payload = &eventspb.ChatEvent_MessageDeleted{MessageDeleted: msg}
+	return event
+}
+
+// NewConversationRead wraps msg like NewMessageSent. Added in schema 1.2.
+func NewConversationRead(msg *eventspb.ConversationRead) *eventspb.ChatEvent {
+	event := newEvent()
+	event.Payload = &eventspb.ChatEvent_ConversationRead{ConversationRead: msg}
+	return event
+}
+
+// NewUnreadChanged wraps msg like NewMessageSent. Added in schema 1.3.
+func NewUnreadChanged(msg *eventspb.UnreadChanged) *eventspb.ChatEvent {
+	event := newEvent()
+	event.Payload = &eventspb.ChatEvent_UnreadChanged{UnreadChanged: msg}
+	return event
+}
+
+func newEvent() *eventspb.ChatEvent {
+	return &eventspb.ChatEvent{
+		MajorVersion: MajorVersion,
+		MinorVersion: MinorVersion,
+		EventId:      uuid.NewString(),
+		OccurredAt:   timestamppb.Now(),
+	}
+}
+
+// KafkaMessage encodes event as the value of a record keyed by key
+func KafkaMessage(key string, event *eventspb.ChatEvent) (kafka.Message, error) {
+	value, err := proto.Marshal(event)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("error encoding event: %w", err)
+	}
+	return kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: ContentTypeHeader, Value: []byte(ContentType)}},
+	}, nil
+}
+
+// Decode parses a record value. Fields added by newer minor versions are
+// skipped, and an event type added by one decodes with a nil payload, which
+// consumers should ignore. Events of any other major version are rejected
+// with ErrUnsupportedVersion.
+func Decode(value []byte) (*eventspb.ChatEvent, error) {
+	var event eventspb.ChatEvent
+	if err := proto.Unmarshal(value, &event); err != nil {
+		return nil, fmt.Errorf("error decoding event: %w", err)
+	}
+	if event.MajorVersion != MajorVersion {
+		return nil, fmt.Errorf("%w: %d.%d", ErrUnsupportedVersion, event.MajorVersion, event.MinorVersion)
+	}
+	if _, err := uuid.Parse(event.EventId); err != nil {
+		return nil, fmt.Errorf("invalid event ID %q", event.EventId)
+	}
+	return &event, nil
+}
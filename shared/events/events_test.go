@@ -0,0 +1,83 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	eventspb "github.com/RishangS/shared/gen/events"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// encode marshals event, failing the test on error
+func encode(t *testing.T, event *eventspb.ChatEvent) []byte {
+	t.Helper()
+	value, err := proto.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// TestDecode checks which record values are accepted across schema versions
+func TestDecode(t *testing.T) {
+	sent := NewMessageSent(&eventspb.MessageSent{Sender: "alice", Recipient: "bob", Content: "hi"})
+
+	newerMajor := NewMessageSent(&eventspb.MessageSent{Sender: "alice"})
+	newerMajor.MajorVersion = MajorVersion + 1
+
+	// A newer minor version may carry fields and payloads this code does
+	// not know; they are appended here as unknown field numbers
+	newerMinor := NewMessageSent(&eventspb.MessageSent{Sender: "alice", Recipient: "bob"})
+	newerMinor.MinorVersion = MinorVersion + 1
+	unknownFields := encode(t, newerMinor)
+	unknownFields = protowire.AppendTag(unknownFields, 1000, protowire.BytesType)
+	unknownFields = protowire.AppendString(unknownFields, "future field")
+
+	unknownPayload := newEvent()
+	unknownPayload.MinorVersion = MinorVersion + 1
+	unknownPayloadValue := protowire.AppendTag(encode(t, unknownPayload), 999, protowire.BytesType)
+	unknownPayloadValue = protowire.AppendBytes(unknownPayloadValue, nil)
+
+	badID := NewMessageSent(&eventspb.MessageSent{Sender: "alice"})
+	badID.EventId = "not-a-uuid"
+
+	noID := NewMessageSent(&eventspb.MessageSent{Sender: "alice"})
+	noID.EventId = ""
+
+	for _, tc := range []struct {
+		name    string
+		value   []byte
+		wantErr error
+		invalid bool
+		sender  string
+	}{
+		{name: "current version", value: encode(t, sent), sender: "alice"},
+		{name: "unknown major version", value: encode(t, newerMajor), wantErr: ErrUnsupportedVersion},
+		{name: "newer minor version with unknown fields", value: unknownFields, sender: "alice"},
+		{name: "newer minor version with unknown payload", value: unknownPayloadValue},
+		{name: "bad event ID", value: encode(t, badID), invalid: true},
+		{name: "missing event ID", value: encode(t, noID), invalid: true},
+		{name: "not protobuf", value: []byte{0xff, 0xff, 0xff}, invalid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event, err := Decode(tc.value)
+			switch {
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tc.wantErr)
+				}
+			case tc.invalid:
+				if err == nil || errors.Is(err, ErrUnsupportedVersion) {
+					t.Fatalf("got error %v, want a decoding error", err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			default:
+				if got := event.GetMessageSent().GetSender(); got != tc.sender {
+					t.Errorf("got sender %q, want %q", got, tc.sender)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	eventspb "github.com/RishangS/shared/gen/events"
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("github.com/RishangS/shared/events")
+
+// Publisher writes events to a writer's topic, each inside a producer span
+// whose context travels in the record headers
+type Publisher struct {
+	writer  *kafka.Writer
+	timeout time.Duration
+}
+
+// NewPublisher returns a Publisher writing with writer. Each Publish call is
+// aborted once timeout elapses.
+func NewPublisher(writer *kafka.Writer, timeout time.Duration) *Publisher {
+	return &Publisher{writer: writer, timeout: timeout}
+}
+
+// Publish writes event keyed by key. Events with the same key go to the same
+// partition and are therefore consumed in the order they were published.
+func (p *Publisher) Publish(ctx context.Context, key string, event *eventspb.ChatEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	record, err := KafkaMessage(key, event)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := tracer.Start(ctx, "kafka.produce "+p.writer.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(tracing.ProducerAttributes(p.writer.Topic)...),
+	)
+	defer span.End()
+	tracing.Inject(ctx, &record)
+
+	start := time.Now()
+	err = p.writer.WriteMessages(ctx, record)
+	metrics.ObserveProduce(p.writer.Topic, start, err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "write failed")
+		return fmt.Errorf("%s topic write error: %w", p.writer.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending writes and closes the writer
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,679 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v3.21.12
+// source: proto/events.proto
+
+package eventspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ChatEvent is the value of every record on the chat topics. Producers set
+// the version they were built against; consumers accept any minor version of
+// the major version they know, ignoring fields they do not recognise, and
+// reject other major versions.
+type ChatEvent struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	MajorVersion uint32                 `protobuf:"varint,1,opt,name=major_version,json=majorVersion,proto3" json:"major_version,omitempty"`
+	MinorVersion uint32                 `protobuf:"varint,2,opt,name=minor_version,json=minorVersion,proto3" json:"minor_version,omitempty"`
+	// event_id is unique per event and lets consumers drop duplicates
+	EventId    string                 `protobuf:"bytes,3,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	OccurredAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ChatEvent_MessageSent
+	//	*ChatEvent_MessageEdited
+	//	*ChatEvent_MessageDeleted
+	//	*ChatEvent_ConversationRead
+	//	*ChatEvent_UnreadChanged
+	Payload       isChatEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatEvent) Reset() {
+	*x = ChatEvent{}
+	mi := &file_proto_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatEvent) ProtoMessage() {}
+
+func (x *ChatEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatEvent.ProtoReflect.Descriptor instead.
+func (*ChatEvent) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ChatEvent) GetMajorVersion() uint32 {
+	if x != nil {
+		return x.MajorVersion
+	}
+	return 0
+}
+
+func (x *ChatEvent) GetMinorVersion() uint32 {
+	if x != nil {
+		return x.MinorVersion
+	}
+	return 0
+}
+
+func (x *ChatEvent) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *ChatEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetPayload() isChatEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetMessageSent() *MessageSent {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatEvent_MessageSent); ok {
+			return x.MessageSent
+		}
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetMessageEdited() *MessageEdited {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatEvent_MessageEdited); ok {
+			return x.MessageEdited
+		}
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetMessageDeleted() *MessageDeleted {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatEvent_MessageDeleted); ok {
+			return x.MessageDeleted
+		}
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetConversationRead() *ConversationRead {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatEvent_ConversationRead); ok {
+			return x.ConversationRead
+		}
+	}
+	return nil
+}
+
+func (x *ChatEvent) GetUnreadChanged() *UnreadChanged {
+	if x != nil {
+		if x, ok := x.Payload.(*ChatEvent_UnreadChanged); ok {
+			return x.UnreadChanged
+		}
+	}
+	return nil
+}
+
+type isChatEvent_Payload interface {
+	isChatEvent_Payload()
+}
+
+type ChatEvent_MessageSent struct {
+	MessageSent *MessageSent `protobuf:"bytes,10,opt,name=message_sent,json=messageSent,proto3,oneof"`
+}
+
+type ChatEvent_MessageEdited struct {
+	MessageEdited *MessageEdited `protobuf:"bytes,11,opt,name=message_edited,json=messageEdited,proto3,oneof"`
+}
+
+type ChatEvent_MessageDeleted struct {
+	MessageDeleted *MessageDeleted `protobuf:"bytes,12,opt,name=message_deleted,json=messageDeleted,proto3,oneof"`
+}
+
+type ChatEvent_ConversationRead struct {
+	ConversationRead *ConversationRead `protobuf:"bytes,13,opt,name=conversation_read,json=conversationRead,proto3,oneof"`
+}
+
+type ChatEvent_UnreadChanged struct {
+	UnreadChanged *UnreadChanged `protobuf:"bytes,14,opt,name=unread_changed,json=unreadChanged,proto3,oneof"`
+}
+
+func (*ChatEvent_MessageSent) isChatEvent_Payload() {}
+
+func (*ChatEvent_MessageEdited) isChatEvent_Payload() {}
+
+func (*ChatEvent_MessageDeleted) isChatEvent_Payload() {}
+
+func (*ChatEvent_ConversationRead) isChatEvent_Payload() {}
+
+func (*ChatEvent_UnreadChanged) isChatEvent_Payload() {}
+
+// MessageSent is a direct message from one user to another
+type MessageSent struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	SenderId int64                  `protobuf:"varint,1,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	Sender   string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	// recipient_id is 0 when the producer only knows the username
+	RecipientId int64  `protobuf:"varint,3,opt,name=recipient_id,json=recipientId,proto3" json:"recipient_id,omitempty"`
+	Recipient   string `protobuf:"bytes,4,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	// content_type describes content, e.g. text/plain
+	ContentType string `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Content     string `protobuf:"bytes,6,opt,name=content,proto3" json:"content,omitempty"`
+	// metadata carries optional client-supplied attributes
+	Metadata      map[string]string `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageSent) Reset() {
+	*x = MessageSent{}
+	mi := &file_proto_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageSent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageSent) ProtoMessage() {}
+
+func (x *MessageSent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageSent.ProtoReflect.Descriptor instead.
+func (*MessageSent) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MessageSent) GetSenderId() int64 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *MessageSent) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *MessageSent) GetRecipientId() int64 {
+	if x != nil {
+		return x.RecipientId
+	}
+	return 0
+}
+
+func (x *MessageSent) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *MessageSent) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *MessageSent) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *MessageSent) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// MessageEdited replaces the content of a sent message. message_id is the
+// event ID of the MessageSent.
+type MessageEdited struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Sender        string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Recipient     string                 `protobuf:"bytes,3,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageEdited) Reset() {
+	*x = MessageEdited{}
+	mi := &file_proto_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageEdited) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageEdited) ProtoMessage() {}
+
+func (x *MessageEdited) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageEdited.ProtoReflect.Descriptor instead.
+func (*MessageEdited) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *MessageEdited) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageEdited) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *MessageEdited) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *MessageEdited) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+// MessageDeleted removes a sent message for both users
+type MessageDeleted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Sender        string                 `protobuf:"bytes,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	Recipient     string                 `protobuf:"bytes,3,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageDeleted) Reset() {
+	*x = MessageDeleted{}
+	mi := &file_proto_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageDeleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageDeleted) ProtoMessage() {}
+
+func (x *MessageDeleted) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageDeleted.ProtoReflect.Descriptor instead.
+func (*MessageDeleted) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MessageDeleted) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageDeleted) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *MessageDeleted) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+// ConversationRead reports that reader has read the conversation with peer
+// up to some message, leaving unread_count messages from peer unread and
+// total_unread across all of reader's conversations
+type ConversationRead struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reader        string                 `protobuf:"bytes,1,opt,name=reader,proto3" json:"reader,omitempty"`
+	Peer          string                 `protobuf:"bytes,2,opt,name=peer,proto3" json:"peer,omitempty"`
+	UnreadCount   int64                  `protobuf:"varint,3,opt,name=unread_count,json=unreadCount,proto3" json:"unread_count,omitempty"`
+	TotalUnread   int64                  `protobuf:"varint,4,opt,name=total_unread,json=totalUnread,proto3" json:"total_unread,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationRead) Reset() {
+	*x = ConversationRead{}
+	mi := &file_proto_events_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationRead) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationRead) ProtoMessage() {}
+
+func (x *ConversationRead) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationRead.ProtoReflect.Descriptor instead.
+func (*ConversationRead) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ConversationRead) GetReader() string {
+	if x != nil {
+		return x.Reader
+	}
+	return ""
+}
+
+func (x *ConversationRead) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *ConversationRead) GetUnreadCount() int64 {
+	if x != nil {
+		return x.UnreadCount
+	}
+	return 0
+}
+
+func (x *ConversationRead) GetTotalUnread() int64 {
+	if x != nil {
+		return x.TotalUnread
+	}
+	return 0
+}
+
+// UnreadChanged reports that a message from peer was stored for user, leaving
+// unread_count messages from peer unread and total_unread across all of
+// user's conversations
+type UnreadChanged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Peer          string                 `protobuf:"bytes,2,opt,name=peer,proto3" json:"peer,omitempty"`
+	UnreadCount   int64                  `protobuf:"varint,3,opt,name=unread_count,json=unreadCount,proto3" json:"unread_count,omitempty"`
+	TotalUnread   int64                  `protobuf:"varint,4,opt,name=total_unread,json=totalUnread,proto3" json:"total_unread,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnreadChanged) Reset() {
+	*x = UnreadChanged{}
+	mi := &file_proto_events_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnreadChanged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnreadChanged) ProtoMessage() {}
+
+func (x *UnreadChanged) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_events_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnreadChanged.ProtoReflect.Descriptor instead.
+func (*UnreadChanged) Descriptor() ([]byte, []int) {
+	return file_proto_events_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UnreadChanged) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *UnreadChanged) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *UnreadChanged) GetUnreadCount() int64 {
+	if x != nil {
+		return x.UnreadCount
+	}
+	return 0
+}
+
+func (x *UnreadChanged) GetTotalUnread() int64 {
+	if x != nil {
+		return x.TotalUnread
+	}
+	return 0
+}
+
+var File_proto_events_proto protoreflect.FileDescriptor
+
+const file_proto_events_proto_rawDesc = "" +
+	"\n" +
+	"\x12proto/events.proto\x12\vchat.events\x1a\x1fgoogle/protobuf/timestamp.proto\"\x97\x04\n" +
+	"\tChatEvent\x12#\n" +
+	"\rmajor_version\x18\x01 \x01(\rR\fmajorVersion\x12#\n" +
+	"\rminor_version\x18\x02 \x01(\rR\fminorVersion\x12\x19\n" +
+	"\bevent_id\x18\x03 \x01(\tR\aeventId\x12;\n" +
+	"\voccurred_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12=\n" +
+	"\fmessage_sent\x18\n" +
+	" \x01(\v2\x18.chat.events.MessageSentH\x00R\vmessageSent\x12C\n" +
+	"\x0emessage_edited\x18\v \x01(\v2\x1a.chat.events.MessageEditedH\x00R\rmessageEdited\x12F\n" +
+	"\x0fmessage_deleted\x18\f \x01(\v2\x1b.chat.events.MessageDeletedH\x00R\x0emessageDeleted\x12L\n" +
+	"\x11conversation_read\x18\r \x01(\v2\x1d.chat.events.ConversationReadH\x00R\x10conversationRead\x12C\n" +
+	"\x0eunread_changed\x18\x0e \x01(\v2\x1a.chat.events.UnreadChangedH\x00R\runreadChangedB\t\n" +
+	"\apayload\"\xc1\x02\n" +
+	"\vMessageSent\x12\x1b\n" +
+	"\tsender_id\x18\x01 \x01(\x03R\bsenderId\x12\x16\n" +
+	"\x06sender\x18\x02 \x01(\tR\x06sender\x12!\n" +
+	"\frecipient_id\x18\x03 \x01(\x03R\vrecipientId\x12\x1c\n" +
+	"\trecipient\x18\x04 \x01(\tR\trecipient\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x12\x18\n" +
+	"\acontent\x18\x06 \x01(\tR\acontent\x12B\n" +
+	"\bmetadata\x18\a \x03(\v2&.chat.events.MessageSent.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"~\n" +
+	"\rMessageEdited\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x16\n" +
+	"\x06sender\x18\x02 \x01(\tR\x06sender\x12\x1c\n" +
+	"\trecipient\x18\x03 \x01(\tR\trecipient\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\"e\n" +
+	"\x0eMessageDeleted\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x16\n" +
+	"\x06sender\x18\x02 \x01(\tR\x06sender\x12\x1c\n" +
+	"\trecipient\x18\x03 \x01(\tR\trecipient\"\x84\x01\n" +
+	"\x10ConversationRead\x12\x16\n" +
+	"\x06reader\x18\x01 \x01(\tR\x06reader\x12\x12\n" +
+	"\x04peer\x18\x02 \x01(\tR\x04peer\x12!\n" +
+	"\funread_count\x18\x03 \x01(\x03R\vunreadCount\x12!\n" +
+	"\ftotal_unread\x18\x04 \x01(\x03R\vtotalUnread\"}\n" +
+	"\rUnreadChanged\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x12\n" +
+	"\x04peer\x18\x02 \x01(\tR\x04peer\x12!\n" +
+	"\funread_count\x18\x03 \x01(\x03R\vunreadCount\x12!\n" +
+	"\ftotal_unread\x18\x04 \x01(\x03R\vtotalUnreadB\x15Z\x13gen/events;eventspbb\x06proto3"
+
+var (
+	file_proto_events_proto_rawDescOnce sync.Once
+	file_proto_events_proto_rawDescData []byte
+)
+
+func file_proto_events_proto_rawDescGZIP() []byte {
+	file_proto_events_proto_rawDescOnce.Do(func() {
+		file_proto_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_events_proto_rawDesc), len(file_proto_events_proto_rawDesc)))
+	})
+	return file_proto_events_proto_rawDescData
+}
+
+var file_proto_events_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_events_proto_goTypes = []any{
+	(*ChatEvent)(nil),             // 0: chat.events.ChatEvent
+	(*MessageSent)(nil),           // 1: chat.events.MessageSent
+	(*MessageEdited)(nil),         // 2: chat.events.MessageEdited
+	(*MessageDeleted)(nil),        // 3: chat.events.MessageDeleted
+	(*ConversationRead)(nil),      // 4: chat.events.ConversationRead
+	(*UnreadChanged)(nil),         // 5: chat.events.UnreadChanged
+	nil,                           // 6: chat.events.MessageSent.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 7: google.protobuf.Timestamp
+}
+var file_proto_events_proto_depIdxs = []int32{
+	7, // 0: chat.events.ChatEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	1, // 1: chat.events.ChatEvent.message_sent:type_name -> chat.events.MessageSent
+	2, // 2: chat.events.ChatEvent.message_edited:type_name -> chat.events.MessageEdited
+	3, // 3: chat.events.ChatEvent.message_deleted:type_name -> chat.events.MessageDeleted
+	4, // 4: chat.events.ChatEvent.conversation_read:type_name -> chat.events.ConversationRead
+	5, // 5: chat.events.ChatEvent.unread_changed:type_name -> chat.events.UnreadChanged
+	6, // 6: chat.events.MessageSent.metadata:type_name -> chat.events.MessageSent.MetadataEntry
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_proto_events_proto_init() }
+func file_proto_events_proto_init() {
+	if File_proto_events_proto != nil {
+		return
+	}
+	file_proto_events_proto_msgTypes[0].OneofWrappers = []any{
+		(*ChatEvent_MessageSent)(nil),
+		(*ChatEvent_MessageEdited)(nil),
+		(*ChatEvent_MessageDeleted)(nil),
+		(*ChatEvent_ConversationRead)(nil),
+		(*ChatEvent_UnreadChanged)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_events_proto_rawDesc), len(file_proto_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_events_proto_goTypes,
+		DependencyIndexes: file_proto_events_proto_depIdxs,
+		MessageInfos:      file_proto_events_proto_msgTypes,
+	}.Build()
+	File_proto_events_proto = out.File
+	file_proto_events_proto_goTypes = nil
+	file_proto_events_proto_depIdxs = nil
+}
@@ -0,0 +1,222 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Status values reported for the service and each dependency
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// Check probes a single dependency and returns an error if it is unusable
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single dependency check
+type CheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Report is the JSON body served by /livez and /readyz
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks registered for a service
+type Checker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewChecker returns a Checker that gives each check at most timeout to answer
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency check under name
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain marks the service as shutting down so readiness fails and the
+// instance is taken out of load balancing before it stops
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Run executes every check concurrently and aggregates the results
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, nc := range c.checks {
+		wg.Add(1)
+		go func(nc namedCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := nc.check(checkCtx)
+			result := CheckResult{Status: StatusOK, LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = StatusFailing
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[nc.name] = result
+			if err != nil {
+				report.Status = StatusFailing
+			}
+		}(nc)
+	}
+	wg.Wait()
+
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+// Ready reports whether every check passes and the service is not draining
+func (c *Checker) Ready(ctx context.Context) bool {
+	return c.Run(ctx).Status == StatusOK
+}
+
+// LivezHandler reports that the process is up; it never probes dependencies
+// so an outage elsewhere does not get healthy pods restarted
+func (c *Checker) LivezHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	}
+}
+
+// ReadyzHandler runs the checks and answers 503 unless all of them pass
+func (c *Checker) ReadyzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeReport(w, code, report)
+	}
+}
+
+// Register adds /livez and /readyz to mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /livez", c.LivezHandler())
+	mux.HandleFunc("GET /readyz", c.ReadyzHandler())
+}
+
+// WatchGRPC keeps the gRPC health service in step with the checks until ctx
+// is cancelled. The overall ("") status and each named service are updated.
+func (c *Checker) WatchGRPC(ctx context.Context, srv *health.Server, interval time.Duration, services ...string) {
+	update := func() {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if c.Ready(ctx) {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		srv.SetServingStatus("", status)
+		for _, service := range services {
+			srv.SetServingStatus(service, status)
+		}
+	}
+
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			update()
+		}
+	}
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Postgres checks that the database answers a ping
+func Postgres(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Kafka checks that at least one of the brokers accepts connections through
+// dialer, which carries any TLS and SASL settings, and returns cluster metadata
+func Kafka(dialer *kafka.Dialer, brokers []string) Check {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, broker := range brokers {
+			conn, err := dialer.DialContext(ctx, "tcp", broker)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			_, err = conn.Brokers()
+			conn.Close()
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+		}
+		if len(errs) == 0 {
+			return errors.New("no brokers configured")
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// GRPC checks a remote service through the standard gRPC health protocol
+func GRPC(conn grpc.ClientConnInterface, service string) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("status %s", resp.GetStatus())
+		}
+		return nil
+	}
+}
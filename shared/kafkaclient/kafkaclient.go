@@ -0,0 +1,127 @@
+package kafkaclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/tlsconfig"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// certReloadInterval is how often the Kafka TLS files are checked for rotation
+const certReloadInterval = 30 * time.Second
+
+// Client holds the broker list with the dialer and transport shared by a
+// service's readers, writers and admin connections, so that TLS and SASL are
+// configured in one place
+type Client struct {
+	Brokers   []string
+	Dialer    *kafka.Dialer
+	Transport *kafka.Transport
+}
+
+// New builds a Client from cfg. clientID identifies the service to the
+// brokers; certificates are reloaded until ctx is cancelled.
+func New(ctx context.Context, cfg config.Kafka, clientID string) (*Client, error) {
+	dialer := &kafka.Dialer{
+		ClientID:  clientID,
+		Timeout:   cfg.Timeout,
+		DualStack: true,
+	}
+	transport := &kafka.Transport{
+		ClientID:    clientID,
+		DialTimeout: cfg.Timeout,
+	}
+
+	if cfg.TLS.Enabled {
+		certs, err := tlsconfig.Start(ctx, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, certReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("error loading Kafka TLS certificates: %w", err)
+		}
+		// The TLS handshake is done while dialling, with a configuration
+		// built per connection so that a rotated CA applies. An empty
+		// server name is filled in with each broker's host.
+		dialer.DialFunc = certs.Dial((&net.Dialer{Timeout: cfg.Timeout}).DialContext, cfg.TLS.ServerName)
+		transport.Dial = dialer.DialFunc
+	}
+
+	if cfg.SASL.Mechanism != "" {
+		mechanism, err := saslMechanism(cfg.SASL)
+		if err != nil {
+			return nil, err
+		}
+		dialer.SASLMechanism = mechanism
+		transport.SASL = mechanism
+	}
+
+	return &Client{Brokers: cfg.Brokers, Dialer: dialer, Transport: transport}, nil
+}
+
+// Writer returns a writer producing to topic with the settings in p. Messages
+// with the same key go to the same partition.
+func (c *Client) Writer(topic string, p config.Producer) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(c.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		Transport:    c.Transport,
+		Compression:  compression(p.Compression),
+		RequiredAcks: requiredAcks(p.RequiredAcks),
+		BatchSize:    p.BatchSize,
+		BatchBytes:   p.BatchBytes,
+		BatchTimeout: p.BatchTimeout,
+	}
+}
+
+// Reader returns a reader for rc, connecting through the client's brokers
+// and dialer
+func (c *Client) Reader(rc kafka.ReaderConfig) *kafka.Reader {
+	rc.Brokers = c.Brokers
+	rc.Dialer = c.Dialer
+	return kafka.NewReader(rc)
+}
+
+func saslMechanism(cfg config.KafkaSASL) (sasl.Mechanism, error) {
+	switch cfg.Mechanism {
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q", cfg.Mechanism)
+	}
+}
+
+func compression(name string) kafka.Compression {
+	switch name {
+	case "gzip":
+		return kafka.Gzip
+	case "snappy":
+		return kafka.Snappy
+	case "lz4":
+		return kafka.Lz4
+	case "zstd":
+		return kafka.Zstd
+	default:
+		return 0
+	}
+}
+
+func requiredAcks(name string) kafka.RequiredAcks {
+	switch name {
+	case "none":
+		return kafka.RequireNone
+	case "one":
+		return kafka.RequireOne
+	default:
+		return kafka.RequireAll
+	}
+}
@@ -0,0 +1,182 @@
+package kafkaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// retentionConfig is the topic setting compared against config.Topic.Retention
+const retentionConfig = "retention.ms"
+
+// TopicSpec is a topic a service depends on and the layout it expects
+type TopicSpec struct {
+	Name string
+	config.Topic
+}
+
+// TopicMismatchError lists every way the cluster disagrees with the specs
+type TopicMismatchError struct {
+	Problems []string
+}
+
+func (e *TopicMismatchError) Error() string {
+	return "Kafka topics do not match the configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// EnsureTopics creates the topics in specs that do not exist yet, when create
+// is set, and then checks that every topic has at least the configured
+// partitions and exactly the configured replication factor and retention.
+// A topic created concurrently by another instance is not an error.
+func (c *Client) EnsureTopics(ctx context.Context, specs []TopicSpec, create bool) error {
+	admin := &kafka.Client{Addr: kafka.TCP(c.Brokers...), Transport: c.Transport}
+
+	topics, err := describeTopics(ctx, admin, specs)
+	if err != nil {
+		return err
+	}
+
+	var missing []kafka.TopicConfig
+	for _, spec := range specs {
+		if _, ok := topics[spec.Name]; ok || !create {
+			continue
+		}
+		topic := kafka.TopicConfig{
+			Topic:             spec.Name,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+		}
+		if spec.Retention > 0 {
+			topic.ConfigEntries = []kafka.ConfigEntry{{
+				ConfigName:  retentionConfig,
+				ConfigValue: strconv.FormatInt(spec.Retention.Milliseconds(), 10),
+			}}
+		}
+		missing = append(missing, topic)
+	}
+
+	if len(missing) > 0 {
+		resp, err := admin.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: missing})
+		if err != nil {
+			return fmt.Errorf("error creating topics: %w", err)
+		}
+		for _, topic := range missing {
+			switch err := resp.Errors[topic.Topic]; {
+			case err == nil:
+				slog.Info("Created Kafka topic", "topic", topic.Topic, "partitions", topic.NumPartitions, "replication_factor", topic.ReplicationFactor)
+			case !errors.Is(err, kafka.TopicAlreadyExists):
+				return fmt.Errorf("error creating topic %s: %w", topic.Topic, err)
+			}
+		}
+
+		if topics, err = describeTopics(ctx, admin, specs); err != nil {
+			return err
+		}
+	}
+
+	retention, err := describeRetention(ctx, admin, specs, topics)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, spec := range specs {
+		topic, ok := topics[spec.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("topic %s does not exist", spec.Name))
+			continue
+		}
+		if len(topic.Partitions) < spec.Partitions {
+			problems = append(problems, fmt.Sprintf("topic %s has %d partitions, expected at least %d",
+				spec.Name, len(topic.Partitions), spec.Partitions))
+		}
+		for _, p := range topic.Partitions {
+			if len(p.Replicas) != spec.ReplicationFactor {
+				problems = append(problems, fmt.Sprintf("topic %s has replication factor %d, expected %d",
+					spec.Name, len(p.Replicas), spec.ReplicationFactor))
+				break
+			}
+		}
+		if spec.Retention > 0 && retention[spec.Name] != spec.Retention {
+			problems = append(problems, fmt.Sprintf("topic %s has retention %s, expected %s",
+				spec.Name, retention[spec.Name], spec.Retention))
+		}
+	}
+	if len(problems) > 0 {
+		return &TopicMismatchError{Problems: problems}
+	}
+	return nil
+}
+
+// describeTopics returns the existing topics among specs by name
+func describeTopics(ctx context.Context, admin *kafka.Client, specs []TopicSpec) (map[string]kafka.Topic, error) {
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+
+	resp, err := admin.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching topic metadata: %w", err)
+	}
+
+	topics := make(map[string]kafka.Topic)
+	for _, topic := range resp.Topics {
+		switch {
+		case topic.Error == nil:
+			topics[topic.Name] = topic
+		case errors.Is(topic.Error, kafka.UnknownTopicOrPartition):
+		default:
+			return nil, fmt.Errorf("error describing topic %s: %w", topic.Name, topic.Error)
+		}
+	}
+	return topics, nil
+}
+
+// describeRetention returns the retention of the existing topics whose spec
+// sets one
+func describeRetention(ctx context.Context, admin *kafka.Client, specs []TopicSpec, existing map[string]kafka.Topic) (map[string]time.Duration, error) {
+	var resources []kafka.DescribeConfigRequestResource
+	for _, spec := range specs {
+		if _, ok := existing[spec.Name]; ok && spec.Retention > 0 {
+			resources = append(resources, kafka.DescribeConfigRequestResource{
+				ResourceType: kafka.ResourceTypeTopic,
+				ResourceName: spec.Name,
+				ConfigNames:  []string{retentionConfig},
+			})
+		}
+	}
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	resp, err := admin.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: resources})
+	if err != nil {
+		return nil, fmt.Errorf("error describing topic configs: %w", err)
+	}
+
+	retention := make(map[string]time.Duration)
+	for _, resource := range resp.Resources {
+		if resource.Error != nil {
+			return nil, fmt.Errorf("error describing topic %s configs: %w", resource.ResourceName, resource.Error)
+		}
+		for _, entry := range resource.ConfigEntries {
+			if entry.ConfigName != retentionConfig {
+				continue
+			}
+			ms, err := strconv.ParseInt(entry.ConfigValue, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q for topic %s", retentionConfig, entry.ConfigValue, resource.ResourceName)
+			}
+			retention[resource.ResourceName] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return retention, nil
+}
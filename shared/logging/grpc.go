@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries the request ID between services
+const RequestIDHeader = "x-request-id"
+
+// UnaryServerInterceptor tags each call's context with a request ID, taken from
+// the x-request-id metadata when present, and logs the outcome of every RPC.
+// Health checks are logged at debug level to keep probes out of the logs.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		requestID := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(RequestIDHeader); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		if requestID == "" {
+			requestID = NewID()
+		}
+		ctx = With(ctx, slog.String(KeyRequestID, requestID))
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		level := slog.LevelInfo
+		if strings.HasPrefix(info.FullMethod, "/grpc.health.") {
+			level = slog.LevelDebug
+		}
+		args := []any{
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			level = slog.LevelWarn
+			args = append(args, KeyError, err)
+		}
+		slog.Log(ctx, level, "rpc finished", args...)
+
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor forwards the request ID found in ctx to the callee
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := RequestID(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// RequestID returns the request ID stored in ctx by With, if any
+func RequestID(ctx context.Context) string {
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key == KeyRequestID {
+			return attrs[i].Value.String()
+		}
+	}
+	return ""
+}
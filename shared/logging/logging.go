@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of any sensitive attribute
+const Redacted = "[REDACTED]"
+
+// Common attribute keys, so every service logs the same names
+const (
+	KeyRequestID = "request_id"
+	KeyConnID    = "conn_id"
+	KeyUsername  = "username"
+	KeyUserID    = "user_id"
+	KeyClientIP  = "client_ip"
+	KeyError     = "error"
+)
+
+// sensitiveKeys are attribute keys, matched case-insensitively as substrings,
+// whose values are never logged
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "authorization", "dsn", "conn_str", "connstr"}
+
+// sensitiveValues match credentials embedded in free-form strings such as
+// error messages: URL userinfo, key=value DSN passwords and bearer tokens
+var sensitiveValues = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(\w+://[^:/@\s]+:)[^@\s]+@`), "${1}" + Redacted + "@"},
+	{regexp.MustCompile(`(?i)((?:password|pwd|secret|token)=)[^\s&;]+`), "${1}" + Redacted},
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + Redacted},
+}
+
+// Init installs a JSON (or format "text") slog logger at level as the default,
+// tagging every record with the service name. The standard log package is
+// routed through it as well.
+func Init(service, level, format string) *slog.Logger {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, defaulting to info\n", err)
+	}
+
+	logger := slog.New(NewHandler(os.Stdout, format, lvl)).With("service", service)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel converts debug, info, warn or error into a slog.Level; empty means info
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// NewHandler returns a redacting handler writing JSON, or text if format is "text",
+// that also adds the attributes stored in the record's context
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var h slog.Handler
+	if strings.EqualFold(format, "text") {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return contextHandler{h}
+}
+
+// redact hides sensitive attributes and scrubs credentials out of string values
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+// IsSensitiveKey reports whether values logged under key must be redacted
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scrub removes credentials embedded in s
+func Scrub(s string) string {
+	for _, sv := range sensitiveValues {
+		s = sv.re.ReplaceAllString(s, sv.repl)
+	}
+	return s
+}
+
+type ctxKey struct{}
+
+// With returns ctx carrying attrs, which are added to every record logged with it
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, ctxKey{}, merged)
+}
+
+// NewID returns a random identifier for a request or connection
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Fatal logs msg at error level and exits, replacing log.Fatalf
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// contextHandler adds the attributes stored by With and the active trace IDs
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
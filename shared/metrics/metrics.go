@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/segmentio/kafka-go"
+)
+
+// Result label values
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+var (
+	kafkaProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_produce_duration_seconds",
+		Help:    "Time taken to write a batch of messages to Kafka.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"topic", "result"})
+
+	kafkaConsumeDelay = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consume_delay_seconds",
+		Help:    "Time between a message being produced and this consumer reading it.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+	}, []string{"topic", "group"})
+
+	kafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag_messages",
+		Help: "Messages remaining in the partition after the last one read.",
+	}, []string{"topic", "group", "partition"})
+)
+
+// Handler serves the default registry, including Go runtime and process metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Register adds /metrics to mux
+func Register(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", Handler())
+}
+
+// NewGRPCServerMetrics returns interceptors recording per-RPC counts, codes and
+// latency. Call InitializeMetrics with the server once services are registered.
+func NewGRPCServerMetrics() *grpcprom.ServerMetrics {
+	m := grpcprom.NewServerMetrics(
+		grpcprom.WithServerHandlingTimeHistogram(
+			grpcprom.WithHistogramBuckets(prometheus.ExponentialBuckets(0.001, 2, 14)),
+		),
+	)
+	prometheus.MustRegister(m)
+	return m
+}
+
+// ObserveProduce records how long a Kafka write to topic took since start
+func ObserveProduce(topic string, start time.Time, err error) {
+	kafkaProduceDuration.WithLabelValues(topic, Result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveConsume records the delivery delay and partition lag of a message
+// read by the consumer group
+func ObserveConsume(group string, msg kafka.Message) {
+	if !msg.Time.IsZero() {
+		kafkaConsumeDelay.WithLabelValues(msg.Topic, group).Observe(time.Since(msg.Time).Seconds())
+	}
+	if msg.HighWaterMark > 0 {
+		lag := msg.HighWaterMark - msg.Offset - 1
+		kafkaConsumerLag.WithLabelValues(msg.Topic, group, strconv.Itoa(msg.Partition)).Set(float64(lag))
+	}
+}
+
+// Result maps err to the result label value
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_event_id;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS event_id;
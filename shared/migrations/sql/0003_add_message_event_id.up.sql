@@ -0,0 +1,4 @@
+-- Event IDs make persisting a redelivered chat event a no-op
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS event_id UUID;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_event_id ON messages(event_id);
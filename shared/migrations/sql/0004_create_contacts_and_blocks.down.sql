@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS contacts_only;
+
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS contacts;
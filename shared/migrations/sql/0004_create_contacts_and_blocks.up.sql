@@ -0,0 +1,20 @@
+-- A user's contacts, and the users they have blocked. Rows are one-way:
+-- adding or blocking someone does not change that user's own lists.
+CREATE TABLE IF NOT EXISTS contacts (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, contact_id),
+    CONSTRAINT contacts_not_self CHECK (user_id <> contact_id)
+);
+
+CREATE TABLE IF NOT EXISTS blocks (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, blocked_id),
+    CONSTRAINT blocks_not_self CHECK (user_id <> blocked_id)
+);
+
+-- Users with contacts_only set accept messages only from their contacts
+ALTER TABLE users ADD COLUMN IF NOT EXISTS contacts_only BOOLEAN NOT NULL DEFAULT FALSE;
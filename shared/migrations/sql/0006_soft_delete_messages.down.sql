@@ -0,0 +1,19 @@
+-- Tombstones and messages of removed accounts cannot be represented without
+-- these columns, so they are dropped
+DELETE FROM messages WHERE deleted_at IS NOT NULL OR sender_id IS NULL OR recipient_id IS NULL;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_recipient_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_recipient_id_fkey
+    FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE messages ALTER COLUMN recipient_id SET NOT NULL;
+ALTER TABLE messages ALTER COLUMN sender_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_messages_deleted_at;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,19 @@
+-- Messages deleted for everyone are kept as tombstones until purged, so that
+-- their event IDs stop a redelivered event from storing them again
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Removing an account keeps its conversations for the other user; the
+-- removed user's side is left NULL
+ALTER TABLE messages ALTER COLUMN sender_id DROP NOT NULL;
+ALTER TABLE messages ALTER COLUMN recipient_id DROP NOT NULL;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_recipient_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_recipient_id_fkey
+    FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_conversation;
+
+DROP TABLE IF EXISTS read_cursors;
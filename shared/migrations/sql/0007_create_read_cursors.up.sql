@@ -0,0 +1,20 @@
+-- How far each user has read each conversation: messages from peer_id with
+-- an ID above last_read_message_id are unread. The message may since have
+-- been purged, so it is not a foreign key.
+CREATE TABLE IF NOT EXISTS read_cursors (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    peer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, peer_id)
+);
+
+-- Start each cursor at the newest message already marked read
+INSERT INTO read_cursors (user_id, peer_id, last_read_message_id)
+SELECT recipient_id, sender_id, MAX(id)
+FROM messages
+WHERE is_read AND sender_id IS NOT NULL AND recipient_id IS NOT NULL
+GROUP BY recipient_id, sender_id
+ON CONFLICT (user_id, peer_id) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(recipient_id, sender_id, id);
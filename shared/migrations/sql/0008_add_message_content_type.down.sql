@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS metadata;
+ALTER TABLE messages DROP COLUMN IF EXISTS content_type;
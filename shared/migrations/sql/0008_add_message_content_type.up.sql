@@ -0,0 +1,4 @@
+-- The content type and client metadata carried by MessageSent events; rows
+-- stored before them are plain text without metadata
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT 'text/plain';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_read BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE messages m
+SET is_read = true
+FROM read_cursors c
+WHERE c.user_id = m.recipient_id AND c.peer_id = m.sender_id AND m.id <= c.last_read_message_id;
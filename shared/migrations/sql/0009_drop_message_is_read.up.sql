@@ -0,0 +1,10 @@
+-- Read state comes from read_cursors alone. Messages flagged read after 0007
+-- without a cursor get one first, like 0007 did.
+INSERT INTO read_cursors (user_id, peer_id, last_read_message_id)
+SELECT recipient_id, sender_id, MAX(id)
+FROM messages
+WHERE is_read AND sender_id IS NOT NULL AND recipient_id IS NOT NULL
+GROUP BY recipient_id, sender_id
+ON CONFLICT (user_id, peer_id) DO NOTHING;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS is_read;
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"database/sql"
+	"log/slog"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/logging"
+)
+
+// OpenDB opens and verifies the PostgreSQL connection pool described by cfg
+func OpenDB(cfg config.Database) *sql.DB {
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		logging.Fatal("Error opening database connection", "error", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		logging.Fatal("Error pinging the database", "error", err, "host", cfg.Host, "port", cfg.Port, "database", cfg.Name)
+	}
+
+	slog.Info("Connected to the PostgreSQL database", "host", cfg.Host, "port", cfg.Port, "database", cfg.Name, "user", cfg.User)
+
+	return db
+}
@@ -0,0 +1,591 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryUserStore is an in-memory UserStore for tests and local development
+type MemoryUserStore struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[int]*User
+}
+
+// NewMemoryUserStore returns an empty MemoryUserStore
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[int]*User)}
+}
+
+// CreateUser creates a new user with hashed password
+func (s *MemoryUserStore) CreateUser(ctx context.Context, username, password, email string) (*User, error) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Username == username || u.Email == email {
+			return nil, ErrUserExists
+		}
+	}
+
+	s.nextID++
+	now := time.Now()
+	user := &User{
+		ID:           s.nextID,
+		Username:     username,
+		PasswordHash: hashedPassword,
+		Email:        email,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		IsActive:     true,
+	}
+	s.users[user.ID] = user
+
+	copied := *user
+	return &copied, nil
+}
+
+// AuthenticateUser verifies username and password
+func (s *MemoryUserStore) AuthenticateUser(ctx context.Context, username, password string) (*User, error) {
+	user, ok := s.byUsername(username)
+	if !ok || !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	return user, nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, id int) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *MemoryUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	user, ok := s.byUsername(username)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// SetActive activates or deactivates an account
+func (s *MemoryUserStore) SetActive(id int, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.IsActive = active
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryUserStore) byUsername(username string) (*User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Username == username {
+			copied := *u
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// MemoryMessageStore is an in-memory MessageStore for tests and local development.
+// Usernames are resolved through the MemoryUserStore it is created with.
+type MemoryMessageStore struct {
+	mu       sync.RWMutex
+	users    *MemoryUserStore
+	nextID   int
+	messages map[int]*Message
+	byEvent  map[string]int
+	edits    map[int][]MessageEdit
+	hidden   map[int]map[int]time.Time // message ID to the users who hid it, and when
+	cursors  map[[2]int]int            // user and peer ID to the last message read
+}
+
+// NewMemoryMessageStore returns an empty MemoryMessageStore resolving users from users
+func NewMemoryMessageStore(users *MemoryUserStore) *MemoryMessageStore {
+	return &MemoryMessageStore{
+		users:    users,
+		messages: make(map[int]*Message),
+		byEvent:  make(map[string]int),
+		edits:    make(map[int][]MessageEdit),
+		hidden:   make(map[int]map[int]time.Time),
+		cursors:  make(map[[2]int]int),
+	}
+}
+
+// CreateMessage stores a message between two usernames and returns its ID
+func (s *MemoryMessageStore) CreateMessage(ctx context.Context, eventID, sender, recipient, contentType, content string, metadata map[string]string) (int, error) {
+	from, ok := s.users.byUsername(sender)
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	to, ok := s.users.byUsername(recipient)
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.byEvent[eventID]; ok && eventID != "" {
+		return id, nil
+	}
+
+	s.nextID++
+	if eventID != "" {
+		s.byEvent[eventID] = s.nextID
+	}
+	s.messages[s.nextID] = &Message{
+		ID:          s.nextID,
+		EventID:     eventID,
+		SenderID:    from.ID,
+		RecipientID: to.ID,
+		ContentType: contentType,
+		Content:     content,
+		Metadata:    maps.Clone(metadata),
+		CreatedAt:   time.Now(),
+	}
+	return s.nextID, nil
+}
+
+// GetMessage retrieves a single message by ID
+func (s *MemoryMessageStore) GetMessage(ctx context.Context, messageID int) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	copied := *msg
+	return &copied, nil
+}
+
+// GetMessageByEventID retrieves a single message by the ID of its chat event
+func (s *MemoryMessageStore) GetMessageByEventID(ctx context.Context, eventID string) (*Message, error) {
+	s.mu.RLock()
+	id, ok := s.byEvent[eventID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	return s.GetMessage(ctx, id)
+}
+
+// GetMessagesByUser retrieves messages sent or received by a user, newest
+// first, leaving out those the user hid
+func (s *MemoryMessageStore) GetMessagesByUser(ctx context.Context, userID int, limit, offset int) ([]Message, error) {
+	return s.filter(limit, offset, func(m *Message) bool {
+		return (m.SenderID == userID || m.RecipientID == userID) && !s.hiddenBy(m.ID, userID)
+	}), nil
+}
+
+// GetConversation retrieves messages between two users as user1ID sees
+// them, newest first
+func (s *MemoryMessageStore) GetConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]Message, error) {
+	return s.filter(limit, offset, func(m *Message) bool {
+		return inConversation(m, user1ID, user2ID) && !s.hiddenBy(m.ID, user1ID)
+	}), nil
+}
+
+// UpdateMessage replaces a message's content and keeps the previous content
+// in the edit history
+func (s *MemoryMessageStore) UpdateMessage(ctx context.Context, messageID int, newContent string) (*Message, error) {
+	var updated Message
+	err := s.update(messageID, func(m *Message) {
+		if m.DeletedAt != nil {
+			return
+		}
+		now := time.Now()
+		s.edits[messageID] = append(s.edits[messageID], MessageEdit{Content: m.Content, EditedAt: now})
+		m.Content = newContent
+		m.EditedAt = &now
+		updated = *m
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated.ID == 0 {
+		return nil, ErrMessageNotFound
+	}
+	return &updated, nil
+}
+
+// ListMessageEdits returns a message's earlier versions, oldest first
+func (s *MemoryMessageStore) ListMessageEdits(ctx context.Context, messageID int) ([]MessageEdit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]MessageEdit(nil), s.edits[messageID]...), nil
+}
+
+// DeleteMessage turns a message into a tombstone and drops its edit history
+func (s *MemoryMessageStore) DeleteMessage(ctx context.Context, messageID, deletedBy int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok || msg.DeletedAt != nil {
+		return ErrMessageNotFound
+	}
+	now := time.Now()
+	msg.Content = ""
+	msg.Metadata = nil
+	msg.DeletedAt = &now
+	msg.DeletedBy = deletedBy
+	delete(s.edits, messageID)
+	return nil
+}
+
+// HideMessage removes a message from userID's view only
+func (s *MemoryMessageStore) HideMessage(ctx context.Context, messageID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.messages[messageID]; !ok {
+		return ErrMessageNotFound
+	}
+	if s.hidden[messageID] == nil {
+		s.hidden[messageID] = make(map[int]time.Time)
+	}
+	if _, ok := s.hidden[messageID][userID]; !ok {
+		s.hidden[messageID][userID] = time.Now()
+	}
+	return nil
+}
+
+// GetUnreadCount returns the count of unread messages for a user
+func (s *MemoryMessageStore) GetUnreadCount(ctx context.Context, userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, m := range s.messages {
+		if s.unread(m, userID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetUnreadCounts returns a user's unread counts for each peer with unread messages
+func (s *MemoryMessageStore) GetUnreadCounts(ctx context.Context, userID int) ([]UnreadCount, error) {
+	s.mu.RLock()
+	byPeer := make(map[int]int)
+	for _, m := range s.messages {
+		if s.unread(m, userID) {
+			byPeer[m.SenderID]++
+		}
+	}
+	s.mu.RUnlock()
+
+	counts := make([]UnreadCount, 0, len(byPeer))
+	for peerID, count := range byPeer {
+		peer, err := s.users.GetUserByID(ctx, peerID)
+		if err != nil {
+			continue
+		}
+		counts = append(counts, UnreadCount{PeerID: peerID, PeerUsername: peer.Username, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].PeerUsername < counts[j].PeerUsername })
+	return counts, nil
+}
+
+// MarkConversationRead moves userID's read cursor for the conversation with
+// peerID forward. IsRead mirrors the cursor: message IDs only grow, so the
+// messages it passes are exactly those at or below it.
+func (s *MemoryMessageStore) MarkConversationRead(ctx context.Context, userID, peerID, messageID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like the Postgres store, a message outside the conversation is reported
+	// before an unknown user
+	if messageID > 0 {
+		m, ok := s.messages[messageID]
+		if !ok || !inConversation(m, userID, peerID) {
+			return 0, ErrMessageNotFound
+		}
+	}
+	for _, id := range []int{userID, peerID} {
+		if _, err := s.users.GetUserByID(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	key := [2]int{userID, peerID}
+	if messageID == 0 {
+		for id, m := range s.messages {
+			if m.RecipientID == userID && m.SenderID == peerID && id > messageID {
+				messageID = id
+			}
+		}
+	}
+	s.cursors[key] = max(s.cursors[key], messageID)
+
+	count := 0
+	for id, m := range s.messages {
+		if m.RecipientID != userID || m.SenderID != peerID {
+			continue
+		}
+		if id <= s.cursors[key] {
+			m.IsRead = true
+		} else if s.unread(m, userID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// PurgeMessages permanently removes up to limit messages nobody can see any more
+func (s *MemoryMessageStore) PurgeMessages(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A participant whose account was removed counts as having hidden the message
+	hiddenBefore := func(messageID, userID int) bool {
+		at, ok := s.hidden[messageID][userID]
+		return userID == 0 || ok && at.Before(cutoff)
+	}
+
+	purged := 0
+	for id, m := range s.messages {
+		if purged >= limit {
+			break
+		}
+		deleted := m.DeletedAt != nil && m.DeletedAt.Before(cutoff)
+		if !deleted && !(hiddenBefore(id, m.SenderID) && hiddenBefore(id, m.RecipientID)) {
+			continue
+		}
+		delete(s.messages, id)
+		delete(s.edits, id)
+		delete(s.hidden, id)
+		if m.EventID != "" {
+			delete(s.byEvent, m.EventID)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// inConversation reports whether m was sent between user1ID and user2ID
+func inConversation(m *Message, user1ID, user2ID int) bool {
+	return (m.SenderID == user1ID && m.RecipientID == user2ID) ||
+		(m.SenderID == user2ID && m.RecipientID == user1ID)
+}
+
+// unread reports whether userID has yet to read m; the caller holds s.mu
+func (s *MemoryMessageStore) unread(m *Message, userID int) bool {
+	return m.RecipientID == userID && m.SenderID != 0 && m.DeletedAt == nil &&
+		m.ID > s.cursors[[2]int{userID, m.SenderID}] && !s.hiddenBy(m.ID, userID)
+}
+
+// hiddenBy reports whether userID hid the message; the caller holds s.mu
+func (s *MemoryMessageStore) hiddenBy(messageID, userID int) bool {
+	_, ok := s.hidden[messageID][userID]
+	return ok
+}
+
+func (s *MemoryMessageStore) update(messageID int, fn func(m *Message)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return ErrMessageNotFound
+	}
+	fn(msg)
+	return nil
+}
+
+// filter returns copies of the matching messages ordered newest first and paginated
+func (s *MemoryMessageStore) filter(limit, offset int, match func(m *Message) bool) []Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var messages []Message
+	for _, m := range s.messages {
+		if match(m) {
+			messages = append(messages, *m)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].ID > messages[j].ID
+		}
+		return messages[i].CreatedAt.After(messages[j].CreatedAt)
+	})
+
+	if offset >= len(messages) {
+		return nil
+	}
+	messages = messages[offset:]
+	if limit >= 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages
+}
+
+// MemoryContactStore is an in-memory ContactStore for tests and local development.
+// Usernames are resolved through the MemoryUserStore it is created with.
+type MemoryContactStore struct {
+	mu           sync.RWMutex
+	users        *MemoryUserStore
+	contacts     map[int]map[int]time.Time
+	blocks       map[int]map[int]time.Time
+	contactsOnly map[int]bool
+}
+
+// NewMemoryContactStore returns an empty MemoryContactStore resolving users from users
+func NewMemoryContactStore(users *MemoryUserStore) *MemoryContactStore {
+	return &MemoryContactStore{
+		users:        users,
+		contacts:     make(map[int]map[int]time.Time),
+		blocks:       make(map[int]map[int]time.Time),
+		contactsOnly: make(map[int]bool),
+	}
+}
+
+// AddContact adds username to userID's contacts
+func (s *MemoryContactStore) AddContact(ctx context.Context, userID int, username string) (*Contact, error) {
+	return s.add(s.contacts, userID, username)
+}
+
+// RemoveContact removes username from userID's contacts
+func (s *MemoryContactStore) RemoveContact(ctx context.Context, userID int, username string) error {
+	return s.remove(s.contacts, userID, username)
+}
+
+// ListContacts returns userID's contacts ordered by username
+func (s *MemoryContactStore) ListContacts(ctx context.Context, userID int) ([]Contact, error) {
+	return s.list(s.contacts, userID), nil
+}
+
+// BlockUser adds username to userID's blocked users
+func (s *MemoryContactStore) BlockUser(ctx context.Context, userID int, username string) (*Contact, error) {
+	return s.add(s.blocks, userID, username)
+}
+
+// UnblockUser removes username from userID's blocked users
+func (s *MemoryContactStore) UnblockUser(ctx context.Context, userID int, username string) error {
+	return s.remove(s.blocks, userID, username)
+}
+
+// ListBlocked returns userID's blocked users ordered by username
+func (s *MemoryContactStore) ListBlocked(ctx context.Context, userID int) ([]Contact, error) {
+	return s.list(s.blocks, userID), nil
+}
+
+// SetContactsOnly sets whether userID accepts messages only from contacts
+func (s *MemoryContactStore) SetContactsOnly(ctx context.Context, userID int, contactsOnly bool) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contactsOnly[userID] = contactsOnly
+	return nil
+}
+
+// CheckMessaging returns nil if sender may message recipient
+func (s *MemoryContactStore) CheckMessaging(ctx context.Context, sender, recipient string) error {
+	from, ok := s.users.byUsername(sender)
+	if !ok {
+		return ErrUserNotFound
+	}
+	to, ok := s.users.byUsername(recipient)
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, blockedBySender := s.blocks[from.ID][to.ID]
+	_, blockedByRecipient := s.blocks[to.ID][from.ID]
+	_, isContact := s.contacts[to.ID][from.ID]
+	return messagingError(blockedBySender, blockedByRecipient, s.contactsOnly[to.ID] && !isContact)
+}
+
+func (s *MemoryContactStore) add(list map[int]map[int]time.Time, userID int, username string) (*Contact, error) {
+	target, ok := s.users.byUsername(username)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if target.ID == userID {
+		return nil, ErrSelfContact
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if list[userID] == nil {
+		list[userID] = make(map[int]time.Time)
+	}
+	createdAt, ok := list[userID][target.ID]
+	if !ok {
+		createdAt = time.Now()
+		list[userID][target.ID] = createdAt
+	}
+	return &Contact{UserID: target.ID, Username: target.Username, CreatedAt: createdAt}, nil
+}
+
+func (s *MemoryContactStore) remove(list map[int]map[int]time.Time, userID int, username string) error {
+	target, ok := s.users.byUsername(username)
+	if !ok {
+		return ErrContactNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, listed := list[userID][target.ID]; !listed {
+		return ErrContactNotFound
+	}
+	delete(list[userID], target.ID)
+	return nil
+}
+
+// list returns copies of userID's entries ordered by username
+func (s *MemoryContactStore) list(list map[int]map[int]time.Time, userID int) []Contact {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var contacts []Contact
+	for id, createdAt := range list[userID] {
+		user, err := s.users.GetUserByID(context.Background(), id)
+		if err != nil {
+			continue
+		}
+		contacts = append(contacts, Contact{UserID: id, Username: user.Username, CreatedAt: createdAt})
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].Username < contacts[j].Username })
+	return contacts
+}
@@ -0,0 +1,772 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// PostgresUserStore is a UserStore backed by the users table
+type PostgresUserStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresUserStore returns a UserStore using the given connection pool.
+// Every query is aborted once timeout elapses; zero leaves only the caller's deadline.
+func NewPostgresUserStore(db *sql.DB, timeout time.Duration) *PostgresUserStore {
+	return &PostgresUserStore{db: db, timeout: timeout}
+}
+
+// PostgresMessageStore is a MessageStore backed by the messages table
+type PostgresMessageStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresMessageStore returns a MessageStore using the given connection pool.
+// Every query is aborted once timeout elapses; zero leaves only the caller's deadline.
+func NewPostgresMessageStore(db *sql.DB, timeout time.Duration) *PostgresMessageStore {
+	return &PostgresMessageStore{db: db, timeout: timeout}
+}
+
+// CreateUser creates a new user with hashed password
+func (s *PostgresUserStore) CreateUser(ctx context.Context, username, password, email string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// Hash the password
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Insert user into database
+	query := `
+		INSERT INTO users (username, password_hash, email)
+		VALUES ($1, $2, $3)
+		RETURNING id, username, password_hash, email, created_at, updated_at, is_active
+	`
+
+	user := &User{}
+	err = s.db.QueryRowContext(ctx, query, username, hashedPassword, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrUserExists
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// AuthenticateUser verifies username and password
+func (s *PostgresUserStore) AuthenticateUser(ctx context.Context, username, password string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := `
+		SELECT id, username, password_hash, email, created_at, updated_at, is_active
+		FROM users
+		WHERE username = $1
+	`
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// Verify password
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	return user, nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *PostgresUserStore) GetUserByID(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := `
+		SELECT id, username, email, created_at, updated_at, is_active
+		FROM users
+		WHERE id = $1
+	`
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByUsername retrieves a user by username
+func (s *PostgresUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := `
+		SELECT id, username, email, created_at, updated_at, is_active
+		FROM users
+		WHERE username = $1
+	`
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.IsActive,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateMessage inserts a new message into the database, or returns the ID of
+// the message already stored for eventID
+func (s *PostgresMessageStore) CreateMessage(ctx context.Context, eventID, sender, recipient, contentType, content string, metadata map[string]string) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding message metadata: %w", err)
+	}
+
+	var messageID int
+	err = s.db.QueryRowContext(ctx,
+		`WITH inserted AS (
+			INSERT INTO messages (event_id, sender_id, recipient_id, content_type, content, metadata)
+			SELECT NULLIF($1, '')::uuid, s.id, r.id, $4, $5, $6
+			FROM users s, users r
+			WHERE s.username = $2 AND r.username = $3
+			ON CONFLICT (event_id) DO NOTHING
+			RETURNING id
+		)
+		SELECT id FROM inserted
+		UNION ALL
+		SELECT id FROM messages WHERE event_id = NULLIF($1, '')::uuid
+		LIMIT 1`,
+		eventID, sender, recipient, contentType, content, encoded,
+	).Scan(&messageID)
+
+	if err != nil {
+		// An unknown username inserts nothing, and no earlier copy exists
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("error creating message: %w", err)
+	}
+	return messageID, nil
+}
+
+// messageColumns are the columns scanMessage reads, in order. A message is
+// read once the recipient's cursor for the conversation has reached it; the
+// subquery's unqualified message columns resolve to the outer row, which is
+// why the queries using it do not need a common alias.
+const messageColumns = `id, COALESCE(event_id::text, ''), COALESCE(sender_id, 0), COALESCE(recipient_id, 0),
+	content_type, content, metadata, created_at, edited_at, deleted_at, COALESCE(deleted_by, 0),
+	EXISTS (SELECT 1 FROM read_cursors rc WHERE rc.user_id = recipient_id AND rc.peer_id = sender_id AND rc.last_read_message_id >= id)`
+
+// scanMessage reads a row selected with messageColumns
+func scanMessage(row interface{ Scan(dest ...any) error }) (Message, error) {
+	var msg Message
+	var editedAt, deletedAt sql.NullTime
+	var metadata []byte
+	err := row.Scan(
+		&msg.ID, &msg.EventID, &msg.SenderID, &msg.RecipientID, &msg.ContentType,
+		&msg.Content, &metadata, &msg.CreatedAt, &editedAt, &deletedAt, &msg.DeletedBy, &msg.IsRead,
+	)
+	if err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(metadata, &msg.Metadata); err != nil {
+		return msg, fmt.Errorf("error decoding message metadata: %w", err)
+	}
+	if len(msg.Metadata) == 0 {
+		msg.Metadata = nil
+	}
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	return msg, err
+}
+
+// GetMessage retrieves a single message by ID
+func (s *PostgresMessageStore) GetMessage(ctx context.Context, messageID int) (*Message, error) {
+	return s.getMessage(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = $1`, messageID)
+}
+
+// GetMessageByEventID retrieves a single message by the ID of its chat event
+func (s *PostgresMessageStore) GetMessageByEventID(ctx context.Context, eventID string) (*Message, error) {
+	// Parsed here rather than cast in SQL, so the lookup uses the event_id
+	// index; a malformed ID cannot match any message
+	id, err := uuid.Parse(eventID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	return s.getMessage(ctx, `SELECT `+messageColumns+` FROM messages WHERE event_id = $1`, id)
+}
+
+// GetMessagesByUser retrieves all messages for a specific user
+func (s *PostgresMessageStore) GetMessagesByUser(ctx context.Context, userID int, limit, offset int) ([]Message, error) {
+	return s.queryMessages(ctx,
+		`SELECT `+messageColumns+`
+		FROM messages m
+		WHERE (recipient_id = $1 OR sender_id = $1)
+		AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+}
+
+// GetConversation retrieves messages between two users as user1ID sees them
+func (s *PostgresMessageStore) GetConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]Message, error) {
+	return s.queryMessages(ctx,
+		`SELECT `+messageColumns+`
+		FROM messages m
+		WHERE ((sender_id = $1 AND recipient_id = $2)
+		OR (sender_id = $2 AND recipient_id = $1))
+		AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`,
+		user1ID, user2ID, limit, offset,
+	)
+}
+
+// UpdateMessage replaces a message's content and records the previous
+// content in message_edits
+func (s *PostgresMessageStore) UpdateMessage(ctx context.Context, messageID int, newContent string) (*Message, error) {
+	// The row lock makes a concurrent edit wait and then record this edit's
+	// content as its previous version, so no version is lost
+	return s.getMessage(ctx,
+		`WITH previous AS (
+			SELECT id AS message_id, content AS old_content
+			FROM messages
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE
+		), recorded AS (
+			INSERT INTO message_edits (message_id, content, edited_at)
+			SELECT message_id, old_content, now() FROM previous
+		)
+		UPDATE messages
+		SET content = $2, edited_at = now()
+		FROM previous
+		WHERE id = previous.message_id
+		RETURNING `+messageColumns,
+		messageID, newContent,
+	)
+}
+
+// ListMessageEdits returns a message's earlier versions, oldest first
+func (s *PostgresMessageStore) ListMessageEdits(ctx context.Context, messageID int) ([]MessageEdit, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT content, edited_at
+		FROM message_edits
+		WHERE message_id = $1
+		ORDER BY edited_at, id`,
+		messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying message edits: %w", err)
+	}
+	defer rows.Close()
+
+	var edits []MessageEdit
+	for rows.Next() {
+		var edit MessageEdit
+		if err := rows.Scan(&edit.Content, &edit.EditedAt); err != nil {
+			return nil, fmt.Errorf("error scanning message edit: %w", err)
+		}
+		edits = append(edits, edit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return edits, nil
+}
+
+// DeleteMessage turns a message into a tombstone and drops its edit history
+func (s *PostgresMessageStore) DeleteMessage(ctx context.Context, messageID, deletedBy int) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var deleted int
+	err := s.db.QueryRowContext(ctx,
+		`WITH tombstone AS (
+			UPDATE messages
+			SET content = '', metadata = '{}', deleted_at = now(), deleted_by = $2
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING id
+		), history AS (
+			DELETE FROM message_edits WHERE message_id IN (SELECT id FROM tombstone)
+		)
+		SELECT count(*) FROM tombstone`,
+		messageID, deletedBy,
+	).Scan(&deleted)
+	if err != nil {
+		return fmt.Errorf("error deleting message: %w", err)
+	}
+	if deleted == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// HideMessage removes a message from userID's view only
+func (s *PostgresMessageStore) HideMessage(ctx context.Context, messageID, userID int) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO message_hidden (message_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (message_id, user_id) DO NOTHING`,
+		messageID, userID,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrMessageNotFound
+		}
+		return fmt.Errorf("error hiding message: %w", err)
+	}
+	return nil
+}
+
+// unreadMessages is the FROM and WHERE clause selecting the messages user $1
+// has not read
+const unreadMessages = `
+	FROM messages m
+	LEFT JOIN read_cursors c ON c.user_id = m.recipient_id AND c.peer_id = m.sender_id
+	WHERE m.recipient_id = $1 AND m.sender_id IS NOT NULL AND m.deleted_at IS NULL
+	AND m.id > COALESCE(c.last_read_message_id, 0)
+	AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = $1)`
+
+// GetUnreadCount returns the count of unread messages for a user
+func (s *PostgresMessageStore) GetUnreadCount(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+unreadMessages, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error getting unread count: %w", err)
+	}
+	return count, nil
+}
+
+// GetUnreadCounts returns a user's unread counts for each peer with unread messages
+func (s *PostgresMessageStore) GetUnreadCounts(ctx context.Context, userID int) ([]UnreadCount, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT u.id, u.username, unread.count
+		FROM (SELECT m.sender_id, COUNT(*) AS count`+unreadMessages+` GROUP BY m.sender_id) unread
+		JOIN users u ON u.id = unread.sender_id
+		ORDER BY u.username`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unread counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []UnreadCount
+	for rows.Next() {
+		var c UnreadCount
+		if err := rows.Scan(&c.PeerID, &c.PeerUsername, &c.Count); err != nil {
+			return nil, fmt.Errorf("error scanning unread count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return counts, nil
+}
+
+// MarkConversationRead moves userID's read cursor for the conversation with
+// peerID forward
+func (s *PostgresMessageStore) MarkConversationRead(ctx context.Context, userID, peerID, messageID int) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// target is messageID if it belongs to the conversation, or the newest
+	// message from peerID when messageID is 0; it is empty otherwise
+	var cursor int
+	err := s.db.QueryRowContext(ctx,
+		`WITH target AS (
+			SELECT id AS last_read
+			FROM messages
+			WHERE $3 > 0 AND id = $3
+			AND ((sender_id = $1 AND recipient_id = $2) OR (sender_id = $2 AND recipient_id = $1))
+			UNION ALL
+			SELECT COALESCE(MAX(id), 0)
+			FROM messages
+			WHERE recipient_id = $1 AND sender_id = $2
+			HAVING $3 = 0
+		), read_cursor AS (
+			INSERT INTO read_cursors (user_id, peer_id, last_read_message_id)
+			SELECT $1, $2, last_read FROM target
+			ON CONFLICT (user_id, peer_id) DO UPDATE
+			SET last_read_message_id = GREATEST(read_cursors.last_read_message_id, EXCLUDED.last_read_message_id),
+				updated_at = now()
+			RETURNING last_read_message_id
+		)
+		SELECT last_read_message_id FROM read_cursor`,
+		userID, peerID, messageID,
+	).Scan(&cursor)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrMessageNotFound
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("error moving read cursor: %w", err)
+	}
+
+	// Counted after the update, which the statement above could not see
+	var count int
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*)`+unreadMessages+` AND m.sender_id = $2`, userID, peerID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error getting unread count: %w", err)
+	}
+	return count, nil
+}
+
+// PurgeMessages permanently removes up to limit messages nobody can see any more
+func (s *PostgresMessageStore) PurgeMessages(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// A participant whose account was removed counts as having hidden the message
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM messages
+		WHERE id IN (
+			SELECT id
+			FROM messages m
+			WHERE m.deleted_at < $1
+			OR (
+				(m.sender_id IS NULL OR EXISTS (
+					SELECT 1 FROM message_hidden h
+					WHERE h.message_id = m.id AND h.user_id = m.sender_id AND h.hidden_at < $1))
+				AND (m.recipient_id IS NULL OR EXISTS (
+					SELECT 1 FROM message_hidden h
+					WHERE h.message_id = m.id AND h.user_id = m.recipient_id AND h.hidden_at < $1))
+			)
+			LIMIT $2
+		)`,
+		cutoff, limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error purging messages: %w", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking rows affected: %w", err)
+	}
+	return int(purged), nil
+}
+
+// getMessage runs a query returning at most one row of messageColumns
+func (s *PostgresMessageStore) getMessage(ctx context.Context, query string, args ...any) (*Message, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	msg, err := scanMessage(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("error getting message: %w", err)
+	}
+	return &msg, nil
+}
+
+// queryMessages runs a query returning rows of messageColumns
+func (s *PostgresMessageStore) queryMessages(ctx context.Context, query string, args ...any) ([]Message, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return messages, nil
+}
+
+// PostgresContactStore is a ContactStore backed by the contacts and blocks tables
+type PostgresContactStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPostgresContactStore returns a ContactStore using the given connection pool.
+// Every query is aborted once timeout elapses; zero leaves only the caller's deadline.
+func NewPostgresContactStore(db *sql.DB, timeout time.Duration) *PostgresContactStore {
+	return &PostgresContactStore{db: db, timeout: timeout}
+}
+
+// Lists kept by PostgresContactStore, as table and user column
+var (
+	contactsList = [2]string{"contacts", "contact_id"}
+	blocksList   = [2]string{"blocks", "blocked_id"}
+)
+
+// AddContact adds username to userID's contacts
+func (s *PostgresContactStore) AddContact(ctx context.Context, userID int, username string) (*Contact, error) {
+	return s.add(ctx, contactsList, userID, username)
+}
+
+// RemoveContact removes username from userID's contacts
+func (s *PostgresContactStore) RemoveContact(ctx context.Context, userID int, username string) error {
+	return s.remove(ctx, contactsList, userID, username)
+}
+
+// ListContacts returns userID's contacts ordered by username
+func (s *PostgresContactStore) ListContacts(ctx context.Context, userID int) ([]Contact, error) {
+	return s.list(ctx, contactsList, userID)
+}
+
+// BlockUser adds username to userID's blocked users
+func (s *PostgresContactStore) BlockUser(ctx context.Context, userID int, username string) (*Contact, error) {
+	return s.add(ctx, blocksList, userID, username)
+}
+
+// UnblockUser removes username from userID's blocked users
+func (s *PostgresContactStore) UnblockUser(ctx context.Context, userID int, username string) error {
+	return s.remove(ctx, blocksList, userID, username)
+}
+
+// ListBlocked returns userID's blocked users ordered by username
+func (s *PostgresContactStore) ListBlocked(ctx context.Context, userID int) ([]Contact, error) {
+	return s.list(ctx, blocksList, userID)
+}
+
+// SetContactsOnly sets whether userID accepts messages only from contacts
+func (s *PostgresContactStore) SetContactsOnly(ctx context.Context, userID int, contactsOnly bool) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET contacts_only = $2 WHERE id = $1`, userID, contactsOnly)
+	if err != nil {
+		return fmt.Errorf("error updating contacts_only: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// CheckMessaging returns nil if sender may message recipient
+func (s *PostgresContactStore) CheckMessaging(ctx context.Context, sender, recipient string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM blocks WHERE user_id = s.id AND blocked_id = r.id),
+			EXISTS (SELECT 1 FROM blocks WHERE user_id = r.id AND blocked_id = s.id),
+			r.contacts_only AND NOT EXISTS (SELECT 1 FROM contacts WHERE user_id = r.id AND contact_id = s.id)
+		FROM users s, users r
+		WHERE s.username = $1 AND r.username = $2
+	`
+
+	var blockedBySender, blockedByRecipient, notContact bool
+	err := s.db.QueryRowContext(ctx, query, sender, recipient).Scan(&blockedBySender, &blockedByRecipient, &notContact)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("error checking messaging permission: %w", err)
+	}
+
+	return messagingError(blockedBySender, blockedByRecipient, notContact)
+}
+
+// add inserts username into one of userID's lists and returns the entry
+func (s *PostgresContactStore) add(ctx context.Context, list [2]string, userID int, username string) (*Contact, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	contact := &Contact{Username: username}
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM users WHERE username = $1`, username).Scan(&contact.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("error looking up user: %w", err)
+	}
+	if contact.UserID == userID {
+		return nil, ErrSelfContact
+	}
+
+	// The no-op update makes RETURNING yield the existing row when re-adding
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (user_id, %[2]s)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, %[2]s) DO UPDATE SET created_at = %[1]s.created_at
+		RETURNING created_at
+	`, list[0], list[1])
+	if err := s.db.QueryRowContext(ctx, query, userID, contact.UserID).Scan(&contact.CreatedAt); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("error adding to %s: %w", list[0], err)
+	}
+
+	return contact, nil
+}
+
+// remove deletes username from one of userID's lists
+func (s *PostgresContactStore) remove(ctx context.Context, list [2]string, userID int, username string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE user_id = $1 AND %[2]s = (SELECT id FROM users WHERE username = $2)
+	`, list[0], list[1])
+	result, err := s.db.ExecContext(ctx, query, userID, username)
+	if err != nil {
+		return fmt.Errorf("error removing from %s: %w", list[0], err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrContactNotFound
+	}
+
+	return nil
+}
+
+// list returns the entries of one of userID's lists ordered by username
+func (s *PostgresContactStore) list(ctx context.Context, list [2]string, userID int) ([]Contact, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT u.id, u.username, l.created_at
+		FROM %[1]s l
+		JOIN users u ON u.id = l.%[2]s
+		WHERE l.user_id = $1
+		ORDER BY u.username
+	`, list[0], list[1])
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying %s: %w", list[0], err)
+	}
+	defer rows.Close()
+
+	var contacts []Contact
+	for rows.Next() {
+		var c Contact
+		if err := rows.Scan(&c.UserID, &c.Username, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning %s: %w", list[0], err)
+		}
+		contacts = append(contacts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/lib/pq"
+)
+
+func init() {
+	sql.Register("blocking", blockingDriver{})
+}
+
+// blockingDriver opens connections on which every statement hangs until its
+// context ends, like a query stuck behind a lock
+type blockingDriver struct{}
+
+func (blockingDriver) Open(string) (driver.Conn, error) { return blockingConn{}, nil }
+
+type blockingConn struct{}
+
+func (blockingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (blockingConn) Close() error                        { return nil }
+func (blockingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingConn) ExecContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// storeCalls returns one call of every Postgres store method
+func storeCalls(db *sql.DB, timeout time.Duration) map[string]func(ctx context.Context) error {
+	users := NewPostgresUserStore(db, timeout)
+	messages := NewPostgresMessageStore(db, timeout)
+	contacts := NewPostgresContactStore(db, timeout)
+	ignore := func(_ any, err error) error { return err }
+	return map[string]func(ctx context.Context) error{
+		"CreateUser":           func(ctx context.Context) error { return ignore(users.CreateUser(ctx, "alice", "pw", "a@b.c")) },
+		"AuthenticateUser":     func(ctx context.Context) error { return ignore(users.AuthenticateUser(ctx, "alice", "password")) },
+		"GetUserByID":          func(ctx context.Context) error { return ignore(users.GetUserByID(ctx, 1)) },
+		"GetUserByUsername":    func(ctx context.Context) error { return ignore(users.GetUserByUsername(ctx, "alice")) },
+		"CreateMessage":        func(ctx context.Context) error { return ignore(messages.CreateMessage(ctx, "", "a", "b", "", "", nil)) },
+		"GetMessage":           func(ctx context.Context) error { return ignore(messages.GetMessage(ctx, 1)) },
+		"GetMessagesByUser":    func(ctx context.Context) error { return ignore(messages.GetMessagesByUser(ctx, 1, 10, 0)) },
+		"GetConversation":      func(ctx context.Context) error { return ignore(messages.GetConversation(ctx, 1, 2, 10, 0)) },
+		"UpdateMessage":        func(ctx context.Context) error { return ignore(messages.UpdateMessage(ctx, 1, "hi")) },
+		"ListMessageEdits":     func(ctx context.Context) error { return ignore(messages.ListMessageEdits(ctx, 1)) },
+		"DeleteMessage":        func(ctx context.Context) error { return messages.DeleteMessage(ctx, 1, 1) },
+		"HideMessage":          func(ctx context.Context) error { return messages.HideMessage(ctx, 1, 1) },
+		"GetUnreadCount":       func(ctx context.Context) error { return ignore(messages.GetUnreadCount(ctx, 1)) },
+		"GetUnreadCounts":      func(ctx context.Context) error { return ignore(messages.GetUnreadCounts(ctx, 1)) },
+		"MarkConversationRead": func(ctx context.Context) error { return ignore(messages.MarkConversationRead(ctx, 1, 2, 0)) },
+		"PurgeMessages":        func(ctx context.Context) error { return ignore(messages.PurgeMessages(ctx, time.Now(), 10)) },
+		"AddContact":           func(ctx context.Context) error { return ignore(contacts.AddContact(ctx, 1, "bob")) },
+		"RemoveContact":        func(ctx context.Context) error { return contacts.RemoveContact(ctx, 1, "bob") },
+		"ListContacts":         func(ctx context.Context) error { return ignore(contacts.ListContacts(ctx, 1)) },
+		"BlockUser":            func(ctx context.Context) error { return ignore(contacts.BlockUser(ctx, 1, "bob")) },
+		"UnblockUser":          func(ctx context.Context) error { return contacts.UnblockUser(ctx, 1, "bob") },
+		"ListBlocked":          func(ctx context.Context) error { return ignore(contacts.ListBlocked(ctx, 1)) },
+		"SetContactsOnly":      func(ctx context.Context) error { return contacts.SetContactsOnly(ctx, 1, true) },
+		"CheckMessaging":       func(ctx context.Context) error { return contacts.CheckMessaging(ctx, "alice", "bob") },
+	}
+}
+
+// TestQueryTimeout checks that every store method gives up on a hung query
+// once Database.QueryTimeout elapses, even when the caller set no deadline
+func TestQueryTimeout(t *testing.T) {
+	db, err := sql.Open("blocking", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.Database{QueryTimeout: 20 * time.Millisecond}
+	for name, call := range storeCalls(db, cfg.QueryTimeout) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Now()
+			err := call(context.Background())
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got %v, want context.DeadlineExceeded", err)
+			}
+			// bcrypt makes CreateUser slower than the timeout on its own
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("returned after %s", elapsed)
+			}
+		})
+	}
+}
+
+// TestQueryCancelled checks that a caller's cancellation, such as a shutdown,
+// aborts a hung query when no timeout is configured
+func TestQueryCancelled(t *testing.T) {
+	db, err := sql.Open("blocking", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := storeCalls(db, 0)["GetConversation"](ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+// TestLockedRowTimeout checks against TEST_DATABASE_URL that a store call
+// stuck behind another transaction's row lock is cancelled on the server once
+// the store's timeout elapses
+func TestLockedRowTimeout(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	user, err := NewPostgresUserStore(db, 0).CreateUser(ctx, "alice", "password", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = NewPostgresContactStore(db, 50*time.Millisecond).SetContactsOnly(ctx, user.ID, true)
+	var pqErr *pq.Error
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &pqErr) && pqErr.Code == "57014") {
+		t.Errorf("got %v, want the query cancelled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s", elapsed)
+	}
+}
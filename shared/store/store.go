@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUserExists is returned when the username or email is already taken
+	ErrUserExists = errors.New("username or email already exists")
+	// ErrInvalidCredentials is returned when the username or password does not match
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUserInactive is returned when authenticating a deactivated account
+	ErrUserInactive = errors.New("account is not active")
+	// ErrUserNotFound is returned when no user matches the lookup
+	ErrUserNotFound = errors.New("user not found")
+	// ErrMessageNotFound is returned when no message matches the lookup
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrContactNotFound is returned when removing a user who is not on the list
+	ErrContactNotFound = errors.New("user is not on the list")
+	// ErrSelfContact is returned when a user adds or blocks themselves
+	ErrSelfContact = errors.New("cannot add or block yourself")
+	// ErrRecipientBlocked is returned when the sender has blocked the recipient
+	ErrRecipientBlocked = errors.New("you have blocked this user")
+	// ErrMessagingNotAllowed is returned when the recipient has blocked the
+	// sender or accepts messages only from contacts
+	ErrMessagingNotAllowed = errors.New("recipient does not accept messages from you")
+)
+
+type User struct {
+	ID           int
+	Username     string
+	PasswordHash string
+	Email        string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	IsActive     bool
+}
+
+// Message represents a message in the database. EventID is the ID of the
+// chat event the message was sent in, which clients use to refer to it.
+// SenderID or RecipientID is 0 once that user's account has been removed.
+// Metadata holds optional client-supplied attributes. A message deleted for
+// everyone is a tombstone: DeletedAt is set and the content is empty.
+type Message struct {
+	ID          int               `json:"id"`
+	EventID     string            `json:"event_id,omitempty"`
+	SenderID    int               `json:"sender_id"`
+	RecipientID int               `json:"recipient_id"`
+	ContentType string            `json:"content_type"`
+	Content     string            `json:"content"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	EditedAt    *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+	DeletedBy   int               `json:"deleted_by,omitempty"`
+	IsRead      bool              `json:"is_read"`
+}
+
+// MessageEdit is an earlier version of a message: its content until the
+// edit made at EditedAt
+type MessageEdit struct {
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// UnreadCount is the number of messages from one user that another has not read
+type UnreadCount struct {
+	PeerID       int
+	PeerUsername string
+	Count        int
+}
+
+// Contact is a user on another user's contact or block list
+type Contact struct {
+	UserID    int
+	Username  string
+	CreatedAt time.Time
+}
+
+// UserStore manages user accounts and credentials
+type UserStore interface {
+	// CreateUser creates a new user with hashed password
+	CreateUser(ctx context.Context, username, password, email string) (*User, error)
+	// AuthenticateUser verifies username and password
+	AuthenticateUser(ctx context.Context, username, password string) (*User, error)
+	// GetUserByID retrieves a user by ID
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	// GetUserByUsername retrieves a user by username
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+}
+
+// MessageStore manages direct messages between users
+type MessageStore interface {
+	// CreateMessage stores a message between two usernames and returns its ID.
+	// A non-empty eventID makes the call idempotent: storing the same event
+	// again returns the existing message's ID. ErrUserNotFound is returned when
+	// either user does not exist.
+	CreateMessage(ctx context.Context, eventID, sender, recipient, contentType, content string, metadata map[string]string) (int, error)
+	// GetMessage retrieves a single message by ID
+	GetMessage(ctx context.Context, messageID int) (*Message, error)
+	// GetMessageByEventID retrieves a single message by the ID of its chat event
+	GetMessageByEventID(ctx context.Context, eventID string) (*Message, error)
+	// GetMessagesByUser retrieves messages sent or received by a user, newest
+	// first, including tombstones but leaving out those the user hid
+	GetMessagesByUser(ctx context.Context, userID int, limit, offset int) ([]Message, error)
+	// GetConversation retrieves messages between two users as user1ID sees
+	// them, newest first, including tombstones but leaving out those user1ID hid
+	GetConversation(ctx context.Context, user1ID, user2ID int, limit, offset int) ([]Message, error)
+	// UpdateMessage replaces a message's content, keeps the previous content
+	// in the edit history and returns the updated message
+	UpdateMessage(ctx context.Context, messageID int, newContent string) (*Message, error)
+	// ListMessageEdits returns a message's earlier versions, oldest first
+	ListMessageEdits(ctx context.Context, messageID int) ([]MessageEdit, error)
+	// DeleteMessage turns a message into a tombstone for both users, dropping
+	// its content, metadata and edit history. ErrMessageNotFound is returned
+	// if it is already deleted.
+	DeleteMessage(ctx context.Context, messageID, deletedBy int) error
+	// HideMessage removes a message from userID's view only; hiding it again
+	// is not an error
+	HideMessage(ctx context.Context, messageID, userID int) error
+	// GetUnreadCount returns the count of unread messages for a user across
+	// all conversations. A message is unread when it is newer than the user's
+	// read cursor for its sender and neither deleted nor hidden.
+	GetUnreadCount(ctx context.Context, userID int) (int, error)
+	// GetUnreadCounts returns a user's unread counts for each peer with
+	// unread messages, ordered by username
+	GetUnreadCounts(ctx context.Context, userID int) ([]UnreadCount, error)
+	// MarkConversationRead moves userID's read cursor for the conversation
+	// with peerID up to messageID, or to the newest message from peerID (the
+	// highest ID) when messageID is 0. The cursor never moves back. It returns
+	// the number of messages from peerID still unread, ErrMessageNotFound if
+	// messageID is not part of the conversation and ErrUserNotFound if either
+	// user does not exist.
+	MarkConversationRead(ctx context.Context, userID, peerID, messageID int) (int, error)
+	// PurgeMessages permanently removes up to limit messages that nobody can
+	// see any more: tombstones deleted before cutoff, and messages every
+	// remaining participant hid before cutoff. It returns how many it removed.
+	PurgeMessages(ctx context.Context, cutoff time.Time, limit int) (int, error)
+}
+
+// ContactStore manages each user's contacts and blocked users, and decides
+// who may message whom
+type ContactStore interface {
+	// AddContact adds username to userID's contacts; re-adding is not an error
+	AddContact(ctx context.Context, userID int, username string) (*Contact, error)
+	// RemoveContact removes username from userID's contacts
+	RemoveContact(ctx context.Context, userID int, username string) error
+	// ListContacts returns userID's contacts ordered by username
+	ListContacts(ctx context.Context, userID int) ([]Contact, error)
+	// BlockUser adds username to userID's blocked users; re-blocking is not an error
+	BlockUser(ctx context.Context, userID int, username string) (*Contact, error)
+	// UnblockUser removes username from userID's blocked users
+	UnblockUser(ctx context.Context, userID int, username string) error
+	// ListBlocked returns userID's blocked users ordered by username
+	ListBlocked(ctx context.Context, userID int) ([]Contact, error)
+	// SetContactsOnly sets whether userID accepts messages only from contacts
+	SetContactsOnly(ctx context.Context, userID int, contactsOnly bool) error
+	// CheckMessaging returns nil if sender may message recipient. It returns
+	// ErrRecipientBlocked if the sender blocked the recipient,
+	// ErrMessagingNotAllowed if the recipient blocked the sender or accepts
+	// only contacts, and ErrUserNotFound if either user does not exist.
+	CheckMessaging(ctx context.Context, sender, recipient string) error
+}
+
+var (
+	_ UserStore    = (*PostgresUserStore)(nil)
+	_ UserStore    = (*MemoryUserStore)(nil)
+	_ MessageStore = (*PostgresMessageStore)(nil)
+	_ MessageStore = (*MemoryMessageStore)(nil)
+	_ ContactStore = (*PostgresContactStore)(nil)
+	_ ContactStore = (*MemoryContactStore)(nil)
+)
+
+// messagingError maps the relationship between a sender and a recipient to
+// the error CheckMessaging returns
+func messagingError(blockedBySender, blockedByRecipient, notContact bool) error {
+	switch {
+	case blockedBySender:
+		return ErrRecipientBlocked
+	case blockedByRecipient, notContact:
+		return ErrMessagingNotAllowed
+	}
+	return nil
+}
+
+// withTimeout bounds ctx by timeout, or only makes it cancellable when timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// hashPassword hashes a plain text password with bcrypt
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// checkPassword reports whether password matches the stored hash
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
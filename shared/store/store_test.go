@@ -0,0 +1,358 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RishangS/shared/migrations"
+	"github.com/google/uuid"
+)
+
+// stores is one implementation of the store interfaces
+type stores struct {
+	users    UserStore
+	messages MessageStore
+	contacts ContactStore
+}
+
+// forEachStore runs test against the in-memory stores and, when
+// TEST_DATABASE_URL names a database, against the Postgres stores, so the
+// fakes are held to the same behaviour
+func forEachStore(t *testing.T, test func(t *testing.T, s stores)) {
+	t.Run("memory", func(t *testing.T) {
+		users := NewMemoryUserStore()
+		test(t, stores{users, NewMemoryMessageStore(users), NewMemoryContactStore(users)})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		db := testDB(t)
+		test(t, stores{NewPostgresUserStore(db, 0), NewPostgresMessageStore(db, 0), NewPostgresContactStore(db, 0)})
+	})
+}
+
+// testDB returns an empty, migrated database, or skips the test when
+// TEST_DATABASE_URL is not set
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `TRUNCATE users RESTART IDENTITY CASCADE`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createUsers creates users with the given names and returns their IDs
+func createUsers(t *testing.T, s stores, names ...string) map[string]int {
+	t.Helper()
+	ids := make(map[string]int)
+	for _, name := range names {
+		u, err := s.users.CreateUser(context.Background(), name, "password-"+name, name+"@example.com")
+		if err != nil {
+			t.Fatalf("CreateUser(%s): %v", name, err)
+		}
+		ids[name] = u.ID
+	}
+	return ids
+}
+
+// send stores a plain text message with client metadata and returns its ID
+// and event ID
+func send(t *testing.T, s stores, from, to, content string) (int, string) {
+	t.Helper()
+	eventID := uuid.NewString()
+	id, err := s.messages.CreateMessage(context.Background(), eventID, from, to, "text/plain", content, map[string]string{"client": "test"})
+	if err != nil {
+		t.Fatalf("CreateMessage(%s to %s): %v", from, to, err)
+	}
+	return id, eventID
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ids := createUsers(t, s, "alice")
+
+		if _, err := s.users.CreateUser(ctx, "alice", "other", "other@example.com"); !errors.Is(err, ErrUserExists) {
+			t.Errorf("duplicate username: got %v, want ErrUserExists", err)
+		}
+		if u, err := s.users.AuthenticateUser(ctx, "alice", "password-alice"); err != nil || u.ID != ids["alice"] {
+			t.Errorf("AuthenticateUser = %v, %v", u, err)
+		}
+		if _, err := s.users.AuthenticateUser(ctx, "alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("wrong password: got %v, want ErrInvalidCredentials", err)
+		}
+		if _, err := s.users.GetUserByUsername(ctx, "nobody"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("unknown user: got %v, want ErrUserNotFound", err)
+		}
+	})
+}
+
+func TestCreateMessage(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		createUsers(t, s, "alice", "bob")
+		id, eventID := send(t, s, "alice", "bob", "hi")
+
+		again, err := s.messages.CreateMessage(ctx, eventID, "alice", "bob", "text/plain", "hi", nil)
+		if err != nil || again != id {
+			t.Errorf("storing the event again = %d, %v; want %d", again, err, id)
+		}
+		if _, err := s.messages.CreateMessage(ctx, uuid.NewString(), "alice", "nobody", "text/plain", "hi", nil); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("unknown recipient: got %v, want ErrUserNotFound", err)
+		}
+
+		msg, err := s.messages.GetMessageByEventID(ctx, eventID)
+		if err != nil || msg.ID != id || msg.Content != "hi" || msg.ContentType != "text/plain" || msg.Metadata["client"] != "test" {
+			t.Errorf("GetMessageByEventID = %+v, %v", msg, err)
+		}
+
+		// Without metadata nothing is stored for it
+		replyID, err := s.messages.CreateMessage(ctx, uuid.NewString(), "bob", "alice", "application/json", `{"x":1}`, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := s.messages.GetMessage(ctx, replyID)
+		if err != nil || reply.ContentType != "application/json" || reply.Metadata != nil {
+			t.Errorf("GetMessage = %+v, %v", reply, err)
+		}
+		for _, unknown := range []string{uuid.NewString(), "not-a-uuid", ""} {
+			if _, err := s.messages.GetMessageByEventID(ctx, unknown); !errors.Is(err, ErrMessageNotFound) {
+				t.Errorf("GetMessageByEventID(%q): got %v, want ErrMessageNotFound", unknown, err)
+			}
+		}
+	})
+}
+
+func TestConversation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ids := createUsers(t, s, "alice", "bob", "carol")
+		first, _ := send(t, s, "alice", "bob", "one")
+		second, _ := send(t, s, "bob", "alice", "two")
+		send(t, s, "carol", "alice", "elsewhere")
+		third, _ := send(t, s, "alice", "bob", "three")
+
+		if err := s.messages.HideMessage(ctx, second, ids["alice"]); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.messages.GetConversation(ctx, ids["alice"], ids["bob"], 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertIDs(t, "alice's view", got, third, first)
+
+		got, err = s.messages.GetConversation(ctx, ids["bob"], ids["alice"], 2, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertIDs(t, "bob's second page", got, second, first)
+	})
+}
+
+func TestEditAndDelete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ids := createUsers(t, s, "alice", "bob")
+		id, _ := send(t, s, "alice", "bob", "one")
+
+		edited, err := s.messages.UpdateMessage(ctx, id, "two")
+		if err != nil || edited.Content != "two" || edited.EditedAt == nil {
+			t.Fatalf("UpdateMessage = %+v, %v", edited, err)
+		}
+		edits, err := s.messages.ListMessageEdits(ctx, id)
+		if err != nil || len(edits) != 1 || edits[0].Content != "one" {
+			t.Errorf("ListMessageEdits = %+v, %v", edits, err)
+		}
+
+		if err := s.messages.DeleteMessage(ctx, id, ids["alice"]); err != nil {
+			t.Fatal(err)
+		}
+		tombstone, err := s.messages.GetMessage(ctx, id)
+		if err != nil || tombstone.DeletedAt == nil || tombstone.DeletedBy != ids["alice"] || tombstone.Content != "" || tombstone.Metadata != nil {
+			t.Errorf("tombstone = %+v, %v", tombstone, err)
+		}
+		if edits, _ := s.messages.ListMessageEdits(ctx, id); len(edits) != 0 {
+			t.Errorf("tombstone kept %d edits", len(edits))
+		}
+		if err := s.messages.DeleteMessage(ctx, id, ids["alice"]); !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("deleting again: got %v, want ErrMessageNotFound", err)
+		}
+		if _, err := s.messages.UpdateMessage(ctx, id, "three"); !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("editing a tombstone: got %v, want ErrMessageNotFound", err)
+		}
+
+		if n, err := s.messages.PurgeMessages(ctx, time.Now().Add(time.Hour), 10); err != nil || n != 1 {
+			t.Errorf("PurgeMessages = %d, %v; want 1", n, err)
+		}
+		if _, err := s.messages.GetMessage(ctx, id); !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("purged message: got %v, want ErrMessageNotFound", err)
+		}
+	})
+}
+
+func TestMarkConversationRead(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ids := createUsers(t, s, "alice", "bob", "carol")
+		alice, bob := ids["alice"], ids["bob"]
+		first, _ := send(t, s, "bob", "alice", "one")
+		second, _ := send(t, s, "bob", "alice", "two")
+		reply, _ := send(t, s, "alice", "bob", "reply")
+		third, _ := send(t, s, "bob", "alice", "three")
+		send(t, s, "carol", "alice", "hello")
+		other, _ := send(t, s, "carol", "bob", "not alice's")
+
+		assertUnread(t, s, alice, 4, UnreadCount{PeerID: bob, PeerUsername: "bob", Count: 3},
+			UnreadCount{PeerID: ids["carol"], PeerUsername: "carol", Count: 1})
+
+		// Up to a message from the peer, then to one the reader sent
+		if n, err := s.messages.MarkConversationRead(ctx, alice, bob, first); err != nil || n != 2 {
+			t.Errorf("read up to the first message = %d, %v; want 2", n, err)
+		}
+		if n, err := s.messages.MarkConversationRead(ctx, alice, bob, reply); err != nil || n != 1 {
+			t.Errorf("read up to the reply = %d, %v; want 1", n, err)
+		}
+		if msg, _ := s.messages.GetMessage(ctx, second); !msg.IsRead {
+			t.Error("a message the cursor passed is not flagged read")
+		}
+
+		// The cursor never moves back
+		if n, err := s.messages.MarkConversationRead(ctx, alice, bob, first); err != nil || n != 1 {
+			t.Errorf("read up to an earlier message = %d, %v; want 1", n, err)
+		}
+
+		// Messages outside the conversation, and unknown users, are refused
+		if _, err := s.messages.MarkConversationRead(ctx, alice, bob, other); !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("another conversation's message: got %v, want ErrMessageNotFound", err)
+		}
+		if _, err := s.messages.MarkConversationRead(ctx, alice, bob, other+100); !errors.Is(err, ErrMessageNotFound) {
+			t.Errorf("unknown message: got %v, want ErrMessageNotFound", err)
+		}
+		if _, err := s.messages.MarkConversationRead(ctx, alice, bob+100, 0); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("unknown peer: got %v, want ErrUserNotFound", err)
+		}
+
+		// Deleted and hidden messages are not unread
+		fourth, _ := send(t, s, "bob", "alice", "four")
+		if err := s.messages.DeleteMessage(ctx, third, bob); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.messages.HideMessage(ctx, fourth, alice); err != nil {
+			t.Fatal(err)
+		}
+		assertUnread(t, s, alice, 1, UnreadCount{PeerID: ids["carol"], PeerUsername: "carol", Count: 1})
+
+		// With no message, up to the newest message from the peer
+		send(t, s, "bob", "alice", "five")
+		if n, err := s.messages.MarkConversationRead(ctx, alice, bob, 0); err != nil || n != 0 {
+			t.Errorf("read everything = %d, %v; want 0", n, err)
+		}
+		assertUnread(t, s, alice, 1, UnreadCount{PeerID: ids["carol"], PeerUsername: "carol", Count: 1})
+	})
+}
+
+func TestCheckMessaging(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ids := createUsers(t, s, "alice", "bob", "carol")
+
+		if _, err := s.contacts.AddContact(ctx, ids["alice"], "alice"); !errors.Is(err, ErrSelfContact) {
+			t.Errorf("adding yourself: got %v, want ErrSelfContact", err)
+		}
+		if _, err := s.contacts.BlockUser(ctx, ids["alice"], "bob"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.contacts.SetContactsOnly(ctx, ids["carol"], true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.contacts.AddContact(ctx, ids["carol"], "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, c := range []struct {
+			from, to string
+			want     error
+		}{
+			{"alice", "bob", ErrRecipientBlocked},
+			{"bob", "alice", ErrMessagingNotAllowed},
+			{"bob", "carol", ErrMessagingNotAllowed},
+			{"alice", "carol", nil},
+			{"carol", "alice", nil},
+			{"alice", "nobody", ErrUserNotFound},
+		} {
+			if err := s.contacts.CheckMessaging(ctx, c.from, c.to); !errors.Is(err, c.want) {
+				t.Errorf("CheckMessaging(%s, %s) = %v, want %v", c.from, c.to, err, c.want)
+			}
+		}
+
+		blocked, err := s.contacts.ListBlocked(ctx, ids["alice"])
+		if err != nil || len(blocked) != 1 || blocked[0].Username != "bob" {
+			t.Errorf("ListBlocked = %+v, %v", blocked, err)
+		}
+		if err := s.contacts.UnblockUser(ctx, ids["alice"], "carol"); !errors.Is(err, ErrContactNotFound) {
+			t.Errorf("unblocking an unblocked user: got %v, want ErrContactNotFound", err)
+		}
+	})
+}
+
+func assertIDs(t *testing.T, name string, got []Message, want ...int) {
+	t.Helper()
+	ids := make([]int, len(got))
+	for i, m := range got {
+		ids[i] = m.ID
+	}
+	if len(ids) != len(want) {
+		t.Errorf("%s: got messages %v, want %v", name, ids, want)
+		return
+	}
+	for i := range ids {
+		if ids[i] != want[i] {
+			t.Errorf("%s: got messages %v, want %v", name, ids, want)
+			return
+		}
+	}
+}
+
+func assertUnread(t *testing.T, s stores, userID, total int, want ...UnreadCount) {
+	t.Helper()
+	ctx := context.Background()
+	if n, err := s.messages.GetUnreadCount(ctx, userID); err != nil || n != total {
+		t.Errorf("GetUnreadCount = %d, %v; want %d", n, err, total)
+	}
+	got, err := s.messages.GetUnreadCounts(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("GetUnreadCounts = %+v, want %+v", got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("GetUnreadCounts = %+v, want %+v", got, want)
+			return
+		}
+	}
+}
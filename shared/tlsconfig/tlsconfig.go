@@ -0,0 +1,260 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// Reloader keeps a certificate/key pair and a CA bundle in memory and reloads
+// them when the files change on disk, so rotated certificates (for example a
+// renewed Kubernetes secret) are picked up without a restart. Either part may
+// be left empty.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	modTime map[string]time.Time
+}
+
+// NewReloader loads the files once, failing if any of them is unusable
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("certificate and key files must be set together")
+	}
+
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		modTime:  make(map[string]time.Time),
+	}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Start loads the files and keeps reloading them every interval until ctx is
+// cancelled
+func Start(ctx context.Context, certFile, keyFile, caFile string, interval time.Duration) (*Reloader, error) {
+	r, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	go r.Run(ctx, interval)
+	return r, nil
+}
+
+// Run checks the files every interval until ctx is cancelled. A failed reload
+// is logged and the previous certificates stay in use.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.reload()
+			if err != nil {
+				slog.Error("Error reloading TLS certificates", "cert", r.certFile, "ca", r.caFile, "error", err)
+			} else if changed {
+				slog.Info("Reloaded TLS certificates", "cert", r.certFile, "ca", r.caFile)
+			}
+		}
+	}
+}
+
+// reload reads the files if any modification time moved and swaps them in
+func (r *Reloader) reload() (bool, error) {
+	modTimes := make(map[string]time.Time)
+	changed := false
+	for _, path := range []string{r.certFile, r.keyFile, r.caFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		modTimes[path] = info.ModTime()
+		if !info.ModTime().Equal(r.modTime[path]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return false, fmt.Errorf("error loading key pair: %w", err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return false, err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bytes.TrimSpace(pem)) {
+			return false, fmt.Errorf("no certificates found in %s", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = cert
+	r.pool = pool
+	r.modTime = modTimes
+	return true, nil
+}
+
+// Certificate returns the current key pair, or nil if none is configured
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Pool returns the current CA bundle, or nil if none is configured
+func (r *Reloader) Pool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerConfig returns a TLS configuration serving the current certificate.
+// With requireClientCert, clients must present a certificate signed by the CA
+// bundle (mutual TLS).
+func (r *Reloader) ServerConfig(requireClientCert bool) (*tls.Config, error) {
+	if r.certFile == "" {
+		return nil, errors.New("a server certificate is required")
+	}
+	if requireClientCert && r.caFile == "" {
+		return nil, errors.New("client certificate verification requires a CA file")
+	}
+
+	// The per-handshake configuration replaces the outer one entirely, and
+	// net/http adds h2 only to its own copy of the outer one, so the
+	// protocols are offered here
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: base.NextProtos,
+		// Built per handshake so that reloaded certificates and CAs apply
+		// to new connections immediately
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cfg := base.Clone()
+			cfg.Certificates = []tls.Certificate{*r.Certificate()}
+			if requireClientCert {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = r.Pool()
+			}
+			return cfg, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a TLS configuration that verifies servers with the
+// standard verifier against the CA bundle, or the system roots if none is
+// set, and presents the current certificate when the server asks for one.
+// serverName overrides the name checked against the server certificate; left
+// empty, the dialled host is checked. The certificate is reloaded, but the
+// CA bundle is read once here; ClientCredentials and Dial build a new
+// configuration per connection so that a rotated CA applies too.
+func (r *Reloader) ClientConfig(serverName string) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+		RootCAs:    r.Pool(),
+	}
+
+	if r.certFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+	return cfg
+}
+
+// ClientCredentials returns gRPC transport credentials that verify servers
+// like ClientConfig, with a configuration built for each connection
+func (r *Reloader) ClientCredentials(serverName string) credentials.TransportCredentials {
+	return &clientCredentials{certs: r, serverName: serverName}
+}
+
+type clientCredentials struct {
+	certs      *Reloader
+	serverName string
+}
+
+func (c *clientCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return credentials.NewTLS(c.certs.ClientConfig(c.serverName)).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *clientCredentials) ServerHandshake(net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("client credentials cannot accept connections")
+}
+
+func (c *clientCredentials) Info() credentials.ProtocolInfo {
+	return credentials.NewTLS(c.certs.ClientConfig(c.serverName)).Info()
+}
+
+func (c *clientCredentials) Clone() credentials.TransportCredentials {
+	return &clientCredentials{certs: c.certs, serverName: c.serverName}
+}
+
+// OverrideServerName is deprecated in gRPC; set the name in ClientCredentials
+func (c *clientCredentials) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
+
+// Dial returns a dial function that wraps connections made by dial in TLS,
+// verifying servers like ClientConfig with a configuration built for each
+// connection. Without serverName the dialled host is checked.
+func (r *Reloader) Dial(dial func(ctx context.Context, network, address string) (net.Conn, error), serverName string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		cfg := r.ClientConfig(serverName)
+		if cfg.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				host = address
+			}
+			cfg.ServerName = host
+		}
+
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
@@ -0,0 +1,209 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCerts writes a CA and a server certificate for dnsName signed by it
+// to dir, returning the certificate, key and CA file paths
+func writeCerts(t *testing.T, dir, dnsName string) (certFile, keyFile, caFile string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, blockType string, bytes []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	return write("tls.crt", "CERTIFICATE", der), write("tls.key", "EC PRIVATE KEY", keyDER), write("ca.crt", "CERTIFICATE", caDER)
+}
+
+// serve accepts TLS connections on a local port until the test ends,
+// completing each handshake and closing the connection
+func serve(t *testing.T, cfg *tls.Config) net.Listener {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	return listener
+}
+
+// TestClientConfigVerifiesServerName checks that the client accepts only a
+// certificate naming the server, including when it dials an IP address
+// without setting a server name
+func TestClientConfigVerifiesServerName(t *testing.T) {
+	certFile, keyFile, caFile := writeCerts(t, t.TempDir(), "auth-service")
+	certs, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := certs.ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := serve(t, serverConfig)
+
+	dialer := &net.Dialer{Timeout: time.Second}
+	for _, tc := range []struct {
+		serverName string
+		ok         bool
+	}{
+		{serverName: "auth-service", ok: true},
+		{serverName: "other-service", ok: false},
+		{serverName: "", ok: false},
+	} {
+		conn, err := tls.DialWithDialer(dialer, "tcp", listener.Addr().String(), certs.ClientConfig(tc.serverName))
+		if err == nil {
+			conn.Close()
+		}
+		if (err == nil) != tc.ok {
+			t.Errorf("server name %q: got error %v, want success %v", tc.serverName, err, tc.ok)
+		}
+	}
+}
+
+// TestServerConfigOffersHTTP2 checks that the per-handshake configuration
+// still negotiates h2, which the HTTP and gateway listeners rely on
+func TestServerConfigOffersHTTP2(t *testing.T) {
+	certFile, keyFile, caFile := writeCerts(t, t.TempDir(), "auth-service")
+	certs, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := certs.ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := serve(t, serverConfig)
+
+	cfg := certs.ClientConfig("auth-service")
+	cfg.NextProtos = []string{"h2", "http/1.1"}
+	conn, err := tls.Dial("tcp", listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.ConnectionState().NegotiatedProtocol; got != "h2" {
+		t.Errorf("negotiated %q, want h2", got)
+	}
+}
+
+// TestDialUsesRotatedCA checks that Dial verifies each connection against
+// the CA bundle current at the time, not the one loaded at startup
+func TestDialUsesRotatedCA(t *testing.T) {
+	oldCert, oldKey, oldCA := writeCerts(t, t.TempDir(), "kafka")
+	newCert, newKey, newCA := writeCerts(t, t.TempDir(), "kafka")
+	server, err := NewReloader(newCert, newKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := server.ServerConfig(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := serve(t, serverConfig)
+
+	client, err := NewReloader(oldCert, oldKey, oldCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := client.Dial((&net.Dialer{Timeout: time.Second}).DialContext, "kafka")
+	if conn, err := dial(context.Background(), "tcp", listener.Addr().String()); err == nil {
+		conn.Close()
+		t.Fatal("server signed by a CA not yet trusted was accepted")
+	}
+
+	// Rotate the client's CA file to the server's CA
+	pem, err := os.ReadFile(newCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldCA, pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(oldCA, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := dial(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("server signed by the rotated CA was refused: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// flushTimeout bounds how long exiting waits for buffered spans to be exported
+const flushTimeout = 5 * time.Second
+
+// Init installs the global tracer provider and W3C trace context propagator.
+// The exporter is chosen by OTEL_TRACES_EXPORTER: "otlp" (configured through the
+// standard OTEL_EXPORTER_OTLP_* variables), "stdout" for local runs, or "none".
+// When unset, otlp is used if OTEL_EXPORTER_OTLP_ENDPOINT is set and none otherwise.
+// The returned function flushes pending spans and must be called before exit.
+func Init(ctx context.Context, serviceName string) (func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func() {}, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %w", err)
+	}
+
+	// The sampler follows OTEL_TRACES_SAMPLER, defaulting to parent-based always-on
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("Error flushing traces", "error", err)
+		}
+	}, nil
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	if name == "" {
+		name = "none"
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			name = "otlp"
+		}
+	}
+
+	switch name {
+	case "otlp":
+		slog.Info("Exporting traces over OTLP")
+		return otlptracegrpc.New(ctx)
+	case "stdout":
+		slog.Info("Exporting traces to stdout")
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, expected otlp, stdout or none", name)
+	}
+}
+
+// Tracer returns a named tracer from the global provider
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// ServerOption traces incoming gRPC calls
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
+
+// DialOption traces outgoing gRPC calls and propagates their context
+func DialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
+
+// HeaderCarrier adapts Kafka message headers to a propagation.TextMapCarrier
+type HeaderCarrier struct {
+	Headers *[]kafka.Header
+}
+
+// Get returns the value of the first header with key
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces any header with key and appends the new value. A new slice is
+// always allocated so messages sharing a header slice are not affected.
+func (c HeaderCarrier) Set(key, value string) {
+	headers := make([]kafka.Header, 0, len(*c.Headers)+1)
+	for _, h := range *c.Headers {
+		if h.Key != key {
+			headers = append(headers, h)
+		}
+	}
+	*c.Headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys lists the header keys
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// Inject writes the trace context of ctx into the message headers
+func Inject(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Headers: &msg.Headers})
+}
+
+// Extract returns ctx carrying the trace context found in the message headers
+func Extract(ctx context.Context, msg kafka.Message) context.Context {
+	headers := msg.Headers
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{Headers: &headers})
+}
+
+// ProducerAttributes describes a Kafka write for a producer span
+func ProducerAttributes(topic string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", topic),
+		attribute.String("messaging.operation.type", "send"),
+	}
+}
+
+// ConsumerAttributes describes a Kafka read for a consumer span
+func ConsumerAttributes(group string, msg kafka.Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.String("messaging.consumer.group.name", group),
+		attribute.String("messaging.operation.type", "process"),
+		attribute.Int("messaging.destination.partition.id", msg.Partition),
+		attribute.Int64("messaging.kafka.offset", msg.Offset),
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Answers from auth-service that refuse an edit or deletion, with the error
+// code and rejectedFrames reason for each
+var changeRefusals = map[codes.Code]struct{ code, reason string }{
+	codes.InvalidArgument:    {codeInvalidMessage, rejectInvalid},
+	codes.NotFound:           {codeNotFound, rejectNotPermitted},
+	codes.PermissionDenied:   {codeForbidden, rejectNotPermitted},
+	codes.FailedPrecondition: {codeEditWindowExpired, rejectNotPermitted},
+}
+
+// editMessage asks auth-service to replace the content of message msg.ID.
+// The call is made for the connection's user, so auth-service checks that
+// the user sent the message, and publishes the edit for both users' sessions.
+func editMessage(ctx context.Context, userID int64, msg Message) (any, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("chat.message_id", msg.ID))
+	callCtx, cancel := context.WithTimeout(asUser(ctx, userID), authTimeout)
+	defer cancel()
+
+	edited, err := authClient.EditMessage(callCtx, &auth.EditMessageRequest{
+		MessageId: msg.ID,
+		Content:   msg.Content,
+	})
+	if err != nil {
+		return changeError(ctx, msg, err), err
+	}
+	sentAt, editedAt := edited.CreatedAt.AsTime(), edited.EditedAt.AsTime()
+	return ackFrame{
+		Type:     frameAck,
+		ClientID: msg.ClientID,
+		ID:       edited.Id,
+		SentAt:   &sentAt,
+		EditedAt: &editedAt,
+	}, nil
+}
+
+// deleteMessage asks auth-service to delete message msg.ID for the user or,
+// with msg.ForEveryone, for both users, like editMessage
+func deleteMessage(ctx context.Context, userID int64, msg Message) (any, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("chat.message_id", msg.ID))
+	callCtx, cancel := context.WithTimeout(asUser(ctx, userID), authTimeout)
+	defer cancel()
+
+	_, err := authClient.DeleteMessage(callCtx, &auth.DeleteMessageRequest{
+		MessageId:   msg.ID,
+		ForEveryone: msg.ForEveryone,
+	})
+	if err != nil {
+		return changeError(ctx, msg, err), err
+	}
+	return ackFrame{Type: frameAck, ClientID: msg.ClientID, ID: msg.ID}, nil
+}
+
+// withToken adds token to ctx as the bearer token auth-service expects
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+// userIDMetadata names the metadata in which auth-service expects the user
+// a call made with the service token acts for
+const userIDMetadata = "x-user-id"
+
+// asUser adds the service token and userID to ctx, so auth-service acts for
+// the user. The connection's own token is not used because it may expire
+// while the connection is open.
+func asUser(ctx context.Context, userID int64) context.Context {
+	return metadata.AppendToOutgoingContext(withToken(ctx, serviceToken), userIDMetadata, strconv.FormatInt(userID, 10))
+}
+
+// changeError returns the error frame answering a failed edit or deletion
+func changeError(ctx context.Context, msg Message, err error) errorFrame {
+	st := status.Convert(err)
+	if r, ok := changeRefusals[st.Code()]; ok {
+		rejectedFrames.WithLabelValues(r.reason).Inc()
+		slog.InfoContext(ctx, "Rejected change", "message_id", msg.ID, "reason", st.Message())
+		return newErrorFrame(msg, r.code, st.Message())
+	}
+	slog.ErrorContext(ctx, "Error changing message", "message_id", msg.ID, "error", err)
+	return newErrorFrame(msg, codeUnavailable, "message could not be changed")
+}
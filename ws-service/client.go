@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Slow consumer policies, applied when a client's send buffer is full
+const (
+	policyDrop       = "drop"
+	policyDisconnect = "disconnect"
+)
+
+// Reasons a connection is reaped by the server, recorded in reapedConnections
+const (
+	reapPongTimeout = "pong_timeout"
+	reapPingFailed  = "ping_failed"
+)
+
+// client is a connected user. Only its write pump writes data frames to the
+// connection; everyone else queues frames with enqueue, so a slow client
+// never blocks the delivery consumer or other clients.
+type client struct {
+	ctx      context.Context // carries the connection's log attributes
+	username string
+	conn     *websocket.Conn
+	settings ClientConfig
+
+	send      chan any
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(ctx context.Context, username string, conn *websocket.Conn, settings ClientConfig) *client {
+	return &client{
+		ctx:      ctx,
+		username: username,
+		conn:     conn,
+		settings: settings,
+		send:     make(chan any, settings.SendBuffer),
+		done:     make(chan struct{}),
+	}
+}
+
+// enqueue queues v for the write pump and reports whether it was accepted.
+// When the buffer is full the frame is dropped or, with the disconnect
+// policy, the client is closed.
+func (c *client) enqueue(v any) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- v:
+		return true
+	default:
+	}
+
+	if c.settings.SlowConsumerPolicy == policyDisconnect {
+		slowConsumers.WithLabelValues(policyDisconnect).Inc()
+		slog.WarnContext(c.ctx, "Disconnecting slow client", "buffered", len(c.send))
+		c.close()
+	} else {
+		slowConsumers.WithLabelValues(policyDrop).Inc()
+		slog.DebugContext(c.ctx, "Dropping frame for slow client", "buffered", len(c.send))
+	}
+	return false
+}
+
+// startHeartbeat arms the read deadline, which every pong pushes back by
+// PongTimeout. A peer that stops answering the write pump's pings fails its
+// next read, so half-open connections do not linger.
+func (c *client) startHeartbeat() {
+	c.conn.SetReadDeadline(time.Now().Add(c.settings.PongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.settings.PongTimeout))
+	})
+}
+
+// reapIfTimedOut closes the connection with a close frame when err is the
+// read deadline expiring, and reports whether it did
+func (c *client) reapIfTimedOut(err error) bool {
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return false
+	}
+	c.reap(reapPongTimeout)
+	return true
+}
+
+// reap closes an unresponsive connection, telling the peer why if it can
+func (c *client) reap(reason string) {
+	reapedConnections.WithLabelValues(reason).Inc()
+	slog.InfoContext(c.ctx, "Closing unresponsive connection", "reason", reason)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "heartbeat timeout")
+	c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	c.close()
+}
+
+// writePump writes queued frames and periodic pings until the client is
+// closed or a write fails
+func (c *client) writePump() {
+	ping := time.NewTicker(c.settings.PingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ping.C:
+			deadline := time.Now().Add(c.settings.WriteTimeout)
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				slog.DebugContext(c.ctx, "Ping failed", "error", err)
+				c.reap(reapPingFailed)
+				return
+			}
+		case v := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.settings.WriteTimeout))
+			if err := c.conn.WriteJSON(v); err != nil {
+				writeErrors.Inc()
+				slog.WarnContext(c.ctx, "Write error", "error", err)
+				c.close()
+				return
+			}
+			framesSent.Inc()
+		}
+	}
+}
+
+// close stops the write pump and closes the connection, which also ends the
+// handler's read loop. It is safe to call more than once.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// registerClient adds c to its user's sessions. A user may be connected from
+// several devices at once, and each session receives the user's frames.
+func registerClient(c *client) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	sessions, ok := clients[c.username]
+	if !ok {
+		sessions = make(map[*client]struct{})
+		clients[c.username] = sessions
+	}
+	sessions[c] = struct{}{}
+}
+
+// unregisterClient removes c from its user's sessions
+func unregisterClient(c *client) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	sessions := clients[c.username]
+	delete(sessions, c)
+	if len(sessions) == 0 {
+		delete(clients, c.username)
+	}
+}
+
+// sessionsOf returns username's connected clients
+func sessionsOf(username string) []*client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	sessions := make([]*client, 0, len(clients[username]))
+	for c := range clients[username] {
+		sessions = append(sessions, c)
+	}
+	return sessions
+}
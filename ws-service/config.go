@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/RishangS/shared/config"
+	"github.com/RishangS/shared/events"
+	"github.com/RishangS/shared/logging"
+)
+
+// Config is the ws-service configuration, loaded by config.Load
+type Config struct {
+	HTTPPort        int           `yaml:"http_port" env:"HTTP_PORT" default:"8081"`
+	AuthServiceAddr string        `yaml:"auth_service_addr" env:"AUTH_SERVICE_ADDR" required:"true"`
+	AuthTimeout     time.Duration `yaml:"auth_timeout" env:"AUTH_TIMEOUT" default:"5s"`
+
+	// ServiceToken authenticates ws-service to auth-service's internal RPCs
+	// and must match auth-service's SERVICE_TOKEN
+	ServiceToken string `yaml:"service_token" env:"SERVICE_TOKEN" required:"true" secret:"true"`
+
+	// AllowedOrigins are the browser origins, besides the service's own host,
+	// whose pages may open a socket; requests without an Origin are allowed
+	AllowedOrigins []string `yaml:"allowed_origins" env:"WS_ALLOWED_ORIGINS"`
+
+	Client     ClientConfig     `yaml:"client"`
+	Limits     LimitsConfig     `yaml:"limits"`
+	Recipients RecipientsConfig `yaml:"recipients"`
+	AuthTLS    AuthTLSConfig    `yaml:"auth_tls"`
+	TLS        config.TLS       `yaml:"tls"`
+	Kafka      KafkaConfig      `yaml:"kafka"`
+	Log        config.Log       `yaml:"log"`
+	Lifecycle  config.Lifecycle `yaml:"lifecycle"`
+}
+
+// ClientConfig bounds what the server buffers for each connection and how
+// long it waits on an unresponsive one
+type ClientConfig struct {
+	SendBuffer         int           `yaml:"send_buffer" env:"WS_SEND_BUFFER" default:"64"`
+	WriteTimeout       time.Duration `yaml:"write_timeout" env:"WS_WRITE_TIMEOUT" default:"10s"`
+	SlowConsumerPolicy string        `yaml:"slow_consumer_policy" env:"WS_SLOW_CONSUMER_POLICY" default:"disconnect"`
+	PingInterval       time.Duration `yaml:"ping_interval" env:"WS_PING_INTERVAL" default:"30s"`
+	PongTimeout        time.Duration `yaml:"pong_timeout" env:"WS_PONG_TIMEOUT" default:"60s"`
+}
+
+// Validate checks the buffer size, timeout and policy name
+func (c *ClientConfig) Validate() []string {
+	var problems []string
+	if c.SendBuffer < 1 {
+		problems = append(problems, "WS_SEND_BUFFER must be at least 1")
+	}
+	if c.WriteTimeout <= 0 {
+		problems = append(problems, "WS_WRITE_TIMEOUT must be positive")
+	}
+	if c.PingInterval <= 0 || c.PongTimeout <= c.PingInterval {
+		problems = append(problems, "WS_PING_INTERVAL must be positive and shorter than WS_PONG_TIMEOUT")
+	}
+	if c.SlowConsumerPolicy != policyDrop && c.SlowConsumerPolicy != policyDisconnect {
+		problems = append(problems, fmt.Sprintf("WS_SLOW_CONSUMER_POLICY must be drop or disconnect, got %q", c.SlowConsumerPolicy))
+	}
+	return problems
+}
+
+// LimitsConfig caps the size of incoming frames and how fast each user and
+// each client IP may send them, and restricts what a message may carry
+type LimitsConfig struct {
+	MaxFrameBytes     int64   `yaml:"max_frame_bytes" env:"WS_MAX_FRAME_BYTES" default:"65536"`
+	MaxContentBytes   int     `yaml:"max_content_bytes" env:"WS_MAX_CONTENT_BYTES" default:"8192"`
+	UserRate          float64 `yaml:"user_rate" env:"WS_USER_RATE" default:"5"`
+	UserBurst         int     `yaml:"user_burst" env:"WS_USER_BURST" default:"20"`
+	IPRate            float64 `yaml:"ip_rate" env:"WS_IP_RATE" default:"20"`
+	IPBurst           int     `yaml:"ip_burst" env:"WS_IP_BURST" default:"50"`
+	UserByteRate      float64 `yaml:"user_byte_rate" env:"WS_USER_BYTE_RATE" default:"16384"`
+	UserByteBurst     int     `yaml:"user_byte_burst" env:"WS_USER_BYTE_BURST" default:"131072"`
+	TrustForwardedFor bool    `yaml:"trust_forwarded_for" env:"WS_TRUST_FORWARDED_FOR"`
+	// MaxRateLimited consecutive rate-limited frames close the connection
+	MaxRateLimited int `yaml:"max_rate_limited" env:"WS_MAX_RATE_LIMITED" default:"20"`
+	// ContentTypes are the content types a message may declare
+	ContentTypes []string `yaml:"content_types" env:"WS_CONTENT_TYPES" default:"text/plain,text/markdown"`
+	// MaxMetadataKeys and MaxMetadataBytes cap a message's metadata; the
+	// byte limit counts every key and value
+	MaxMetadataKeys  int `yaml:"max_metadata_keys" env:"WS_MAX_METADATA_KEYS" default:"16"`
+	MaxMetadataBytes int `yaml:"max_metadata_bytes" env:"WS_MAX_METADATA_BYTES" default:"1024"`
+}
+
+// Validate checks that the sizes, rates and bursts are positive, that a
+// maximum-size message fits in a frame and a maximum-size frame in the byte
+// burst, and that text/plain is allowed
+func (l *LimitsConfig) Validate() []string {
+	var problems []string
+	if l.MaxContentBytes < 1 || int64(l.MaxContentBytes) >= l.MaxFrameBytes {
+		problems = append(problems, "WS_MAX_CONTENT_BYTES must be positive and smaller than WS_MAX_FRAME_BYTES")
+	}
+	if l.MaxMetadataKeys < 0 || l.MaxMetadataBytes < 0 || int64(l.MaxContentBytes+l.MaxMetadataBytes) >= l.MaxFrameBytes {
+		problems = append(problems, "WS_MAX_METADATA_KEYS and WS_MAX_METADATA_BYTES must not be negative, and WS_MAX_CONTENT_BYTES plus WS_MAX_METADATA_BYTES must be smaller than WS_MAX_FRAME_BYTES")
+	}
+	// Messages without a content type are sent as text/plain
+	if !slices.Contains(l.ContentTypes, events.ContentTypeText) {
+		problems = append(problems, "WS_CONTENT_TYPES must include "+events.ContentTypeText)
+	}
+	if l.UserRate <= 0 || l.UserBurst < 1 {
+		problems = append(problems, "WS_USER_RATE must be positive and WS_USER_BURST at least 1")
+	}
+	if l.IPRate <= 0 || l.IPBurst < 1 {
+		problems = append(problems, "WS_IP_RATE must be positive and WS_IP_BURST at least 1")
+	}
+	// A frame larger than the byte burst could never be accepted
+	if l.UserByteRate <= 0 || int64(l.UserByteBurst) < l.MaxFrameBytes {
+		problems = append(problems, "WS_USER_BYTE_RATE must be positive and WS_USER_BYTE_BURST at least WS_MAX_FRAME_BYTES")
+	}
+	if l.MaxRateLimited < 1 {
+		problems = append(problems, "WS_MAX_RATE_LIMITED must be at least 1")
+	}
+	return problems
+}
+
+// RecipientsConfig sizes the cache of recipient and permission lookups made
+// to auth-service
+type RecipientsConfig struct {
+	CacheTTL      time.Duration `yaml:"cache_ttl" env:"RECIPIENT_CACHE_TTL" default:"5m"`
+	NegativeTTL   time.Duration `yaml:"negative_ttl" env:"RECIPIENT_NEGATIVE_TTL" default:"30s"`
+	PermissionTTL time.Duration `yaml:"permission_ttl" env:"RECIPIENT_PERMISSION_TTL" default:"10s"`
+	CacheSize     int           `yaml:"cache_size" env:"RECIPIENT_CACHE_SIZE" default:"10000"`
+}
+
+// Validate checks that the TTLs and size are positive
+func (r *RecipientsConfig) Validate() []string {
+	var problems []string
+	if r.CacheTTL <= 0 || r.NegativeTTL <= 0 || r.PermissionTTL <= 0 {
+		problems = append(problems, "RECIPIENT_CACHE_TTL, RECIPIENT_NEGATIVE_TTL and RECIPIENT_PERMISSION_TTL must be positive")
+	}
+	if r.CacheSize < 1 {
+		problems = append(problems, "RECIPIENT_CACHE_SIZE must be at least 1")
+	}
+	return problems
+}
+
+// AuthTLSConfig secures the connection to auth-service. A client certificate
+// is needed when auth-service requires mutual TLS.
+type AuthTLSConfig struct {
+	Enabled    bool   `yaml:"enabled" env:"AUTH_TLS_ENABLED"`
+	CAFile     string `yaml:"ca_file" env:"AUTH_TLS_CA_FILE"`
+	CertFile   string `yaml:"cert_file" env:"AUTH_TLS_CERT_FILE"`
+	KeyFile    string `yaml:"key_file" env:"AUTH_TLS_KEY_FILE"`
+	ServerName string `yaml:"server_name" env:"AUTH_TLS_SERVER_NAME"`
+}
+
+// Validate checks that the client certificate and key come together
+func (a *AuthTLSConfig) Validate() []string {
+	var problems []string
+	if (a.CertFile == "") != (a.KeyFile == "") {
+		problems = append(problems, "AUTH_TLS_CERT_FILE and AUTH_TLS_KEY_FILE must be set together")
+	}
+	if !a.Enabled && (a.CAFile != "" || a.CertFile != "") {
+		problems = append(problems, "AUTH_TLS_* files are set but AUTH_TLS_ENABLED is false")
+	}
+	return problems
+}
+
+// KafkaConfig selects the topic chat messages are published to, which both
+// the delivery consumer and persistence-service read, and tunes its producer
+type KafkaConfig struct {
+	config.Kafka  `yaml:",inline"`
+	MessagesTopic string        `yaml:"messages_topic" env:"KAFKA_MESSAGES_TOPIC" default:"messages"`
+	WriteTimeout  time.Duration `yaml:"write_timeout" env:"KAFKA_WRITE_TIMEOUT" default:"5s"`
+
+	MessagesProducer    config.Producer `yaml:"messages_producer" env_prefix:"KAFKA_MESSAGES_"`
+	MessagesTopicConfig config.Topic    `yaml:"messages_topic_config" env_prefix:"KAFKA_MESSAGES_"`
+}
+
+// Validate checks the port, timeouts and origins
+func (c *Config) Validate() []string {
+	problems := config.ValidPort("HTTP_PORT", c.HTTPPort)
+	problems = append(problems, config.ValidOrigins("WS_ALLOWED_ORIGINS", c.AllowedOrigins)...)
+	if c.AuthTimeout <= 0 {
+		problems = append(problems, "AUTH_TIMEOUT must be positive")
+	}
+	if c.Kafka.WriteTimeout <= 0 {
+		problems = append(problems, "KAFKA_WRITE_TIMEOUT must be positive")
+	}
+	if c.TLS.ClientAuth {
+		problems = append(problems, "TLS_CLIENT_AUTH is not supported on the WebSocket listener")
+	}
+	return problems
+}
+
+// loadConfig loads the configuration, exiting with every problem listed if it
+// is invalid, then sets up logging and prints the effective values
+func loadConfig() Config {
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Init("ws-service", cfg.Log.Level, cfg.Log.Format)
+	slog.Info("Effective configuration", "config", config.Redact(&cfg))
+	return cfg
+}
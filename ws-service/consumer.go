@@ -1,57 +1,137 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log"
-
-	"github.com/segmentio/kafka-go"
-)
-
-func startKafkaConsumer() {
-	kafkaBrokers := getEnv("KAFKA_BROKERS", "localhost:9092")
-	fmt.Println("kafkaBrokers", kafkaBrokers)
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{kafkaBrokers},
-		Topic:   "messages",
-		GroupID: "websocket-delivery",
-	})
-	defer reader.Close()
-
-	for {
-		msg, err := reader.ReadMessage(context.Background())
-		if err != nil {
-			log.Printf("Kafka read error: %v", err)
-			continue
-		}
-
-		// Extract recipient from headers
-		var to string
-		for _, header := range msg.Headers {
-			if header.Key == "To" {
-				to = string(header.Value)
-				break
-			}
-		}
-
-		if to == "" {
-			continue
-		}
-
-		// Find recipient's connection
-		clientsMu.Lock()
-		conn, ok := clients[to]
-		clientsMu.Unlock()
-
-		if ok {
-			// Send message to recipient
-			err := conn.WriteJSON(map[string]string{
-				"from":    string(msg.Key),
-				"content": string(msg.Value),
-			})
-			if err != nil {
-				log.Printf("Write error to %s: %v", to, err)
-			}
-		}
-	}
-}
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	"github.com/RishangS/shared/kafkaclient"
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const deliveryGroupID = "websocket-delivery"
+
+func startKafkaConsumer(ctx context.Context, client *kafkaclient.Client, cfg KafkaConfig) {
+	slog.Info("Starting Kafka consumer", "brokers", client.Brokers, "topic", cfg.MessagesTopic, "group", deliveryGroupID)
+	reader := client.Reader(kafka.ReaderConfig{
+		Topic:   cfg.MessagesTopic,
+		GroupID: deliveryGroupID,
+	})
+	defer reader.Close()
+
+	consume(ctx, reader, deliver)
+}
+
+// messageReader is the part of *kafka.Reader the consumer uses
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+}
+
+// consume reads messages until ctx is done, passing each to handle
+func consume(ctx context.Context, reader messageReader, handle func(context.Context, kafka.Message)) {
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.ErrorContext(ctx, "Kafka read error", "error", err)
+			continue
+		}
+		metrics.ObserveConsume(deliveryGroupID, msg)
+
+		handle(ctx, msg)
+	}
+}
+
+// deliver writes a consumed event to the connections it concerns, if any,
+// inside a consumer span continuing the trace started by the sender's frame
+func deliver(ctx context.Context, msg kafka.Message) {
+	ctx, span := tracer.Start(tracing.Extract(ctx, msg), "ws.deliver",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(tracing.ConsumerAttributes(deliveryGroupID, msg)...),
+	)
+	defer span.End()
+
+	event, err := events.Decode(msg.Value)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid event")
+		slog.WarnContext(ctx, "Skipping invalid event", "offset", msg.Offset, "error", err)
+		return
+	}
+
+	// Sent messages go to the recipient; edits and deletions to both users,
+	// since either may be showing the message; reads to the reader, even when
+	// made over REST; and the counts raised by a stored message to its
+	// recipient. Other event types are ignored.
+	switch p := event.Payload.(type) {
+	case *eventspb.ChatEvent_MessageSent:
+		sent := p.MessageSent
+		enqueueFor(span, sent.Recipient, messageFrame{
+			Type:        frameMessage,
+			ID:          event.EventId,
+			From:        sent.Sender,
+			Content:     sent.Content,
+			ContentType: sent.ContentType,
+			Metadata:    sent.Metadata,
+			SentAt:      event.OccurredAt.AsTime(),
+		})
+	case *eventspb.ChatEvent_MessageEdited:
+		edited := p.MessageEdited
+		frame := messageEditedFrame{
+			Type:     frameMessageEdited,
+			ID:       edited.MessageId,
+			From:     edited.Sender,
+			To:       edited.Recipient,
+			Content:  edited.Content,
+			EditedAt: event.OccurredAt.AsTime(),
+		}
+		enqueueFor(span, edited.Sender, frame)
+		enqueueFor(span, edited.Recipient, frame)
+	case *eventspb.ChatEvent_MessageDeleted:
+		deleted := p.MessageDeleted
+		frame := messageDeletedFrame{
+			Type:      frameMessageDeleted,
+			ID:        deleted.MessageId,
+			From:      deleted.Sender,
+			To:        deleted.Recipient,
+			DeletedAt: event.OccurredAt.AsTime(),
+		}
+		enqueueFor(span, deleted.Sender, frame)
+		enqueueFor(span, deleted.Recipient, frame)
+	case *eventspb.ChatEvent_ConversationRead:
+		read := p.ConversationRead
+		enqueueFor(span, read.Reader, unreadFrame{
+			Type:          frameUnread,
+			Conversations: []unreadConversation{{With: read.Peer, Unread: read.UnreadCount}},
+			TotalUnread:   read.TotalUnread,
+		})
+	case *eventspb.ChatEvent_UnreadChanged:
+		changed := p.UnreadChanged
+		enqueueFor(span, changed.User, unreadFrame{
+			Type:          frameUnread,
+			Conversations: []unreadConversation{{With: changed.Peer, Unread: changed.UnreadCount}},
+			TotalUnread:   changed.TotalUnread,
+		})
+	}
+}
+
+// enqueueFor queues frame for the write pump of each of username's
+// connections, if any; a slow client never blocks the consumer
+func enqueueFor(span trace.Span, username string, frame any) {
+	if username == "" {
+		return
+	}
+	for _, c := range sessionsOf(username) {
+		if !c.enqueue(frame) {
+			span.AddEvent("send buffer full", trace.WithAttributes(attribute.String("chat.to", username)))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fakeReader hands out queued messages, then a read error if set, and then
+// blocks like kafka.Reader until the context ends
+type fakeReader struct {
+	messages chan kafka.Message
+	err      error
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-r.messages:
+		return m, nil
+	default:
+	}
+	if err := r.err; err != nil {
+		r.err = nil
+		return kafka.Message{}, err
+	}
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func TestConsumeReturnsWhenCancelled(t *testing.T) {
+	reader := &fakeReader{messages: make(chan kafka.Message, 2), err: errors.New("broker unavailable")}
+	reader.messages <- kafka.Message{Offset: 1}
+	reader.messages <- kafka.Message{Offset: 2}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consume(ctx, reader, func(_ context.Context, msg kafka.Message) {
+			handled = append(handled, msg.Offset)
+		})
+	}()
+
+	// A read error is logged and the loop keeps reading until cancelled
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consume did not return after cancellation")
+	}
+	if len(handled) != 2 || handled[0] != 1 || handled[1] != 2 {
+		t.Errorf("handled offsets %v, want [1 2]", handled)
+	}
+}
+
+func TestEnqueueForReachesEverySession(t *testing.T) {
+	settings := ClientConfig{SendBuffer: 1, SlowConsumerPolicy: policyDrop}
+	phone := newClient(context.Background(), "bob", nil, settings)
+	laptop := newClient(context.Background(), "bob", nil, settings)
+	registerClient(phone)
+	registerClient(laptop)
+	defer unregisterClient(laptop)
+
+	span := trace.SpanFromContext(context.Background())
+	enqueueFor(span, "bob", "first")
+	for name, c := range map[string]*client{"phone": phone, "laptop": laptop} {
+		if got := len(c.send); got != 1 {
+			t.Errorf("%s has %d frames queued, want 1", name, got)
+		}
+	}
+
+	// A closed session stops receiving while the other stays registered
+	unregisterClient(phone)
+	<-laptop.send
+	enqueueFor(span, "bob", "second")
+	if got := len(phone.send); got != 1 {
+		t.Errorf("unregistered phone has %d frames queued, want only the first", got)
+	}
+	if got := <-laptop.send; got != "second" {
+		t.Errorf("laptop got %v, want second", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import "time"
+
+// Frame types, sent in every server frame's "type" field
+const (
+	frameMessage        = "message"
+	frameMessageEdited  = "message_edited"
+	frameMessageDeleted = "message_deleted"
+	frameUnread         = "unread"
+	frameAck            = "ack"
+	frameError          = "error"
+)
+
+// Client frame types besides "message", which is also assumed when the type is empty
+const (
+	frameEdit   = "edit"
+	frameDelete = "delete"
+	frameRead   = "read"
+)
+
+// Error codes sent in error frames
+const (
+	codeInvalidMessage    = "invalid_message"
+	codeMessageTooLarge   = "message_too_large"
+	codeRateLimited       = "rate_limited"
+	codeUnknownRecipient  = "unknown_recipient"
+	codeInactiveRecipient = "inactive_recipient"
+	codeRecipientBlocked  = "recipient_blocked"
+	codeNotAccepted       = "not_accepted"
+	codeUnavailable       = "unavailable"
+	codePublishFailed     = "publish_failed"
+	codeNotFound          = "not_found"
+	codeForbidden         = "forbidden"
+	codeEditWindowExpired = "edit_window_expired"
+)
+
+// retryableCodes are the errors a client may resend the same message after
+var retryableCodes = map[string]bool{
+	codeRateLimited:   true,
+	codeUnavailable:   true,
+	codePublishFailed: true,
+}
+
+// maxClientIDLength bounds the client-chosen ID echoed back in replies
+const maxClientIDLength = 128
+
+// Message represents the WebSocket message structure. ClientID is chosen by
+// the client and echoed in the ack or error frame answering the message.
+// Edit and delete frames name the message to change by ID, the ID its ack
+// carried. Read frames name the conversation by the other user, With, and
+// optionally the last message read by ID.
+type Message struct {
+	Type        string            `json:"type,omitempty"`
+	ClientID    string            `json:"client_id,omitempty"`
+	ID          string            `json:"id,omitempty"`
+	With        string            `json:"with,omitempty"`
+	To          string            `json:"to"`
+	Content     string            `json:"content"`
+	ContentType string            `json:"content_type,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ForEveryone bool              `json:"for_everyone,omitempty"`
+}
+
+// messageFrame delivers a message to its recipient
+type messageFrame struct {
+	Type        string            `json:"type"`
+	ID          string            `json:"id"`
+	From        string            `json:"from"`
+	Content     string            `json:"content"`
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	SentAt      time.Time         `json:"sent_at"`
+}
+
+// messageEditedFrame tells both users that a message's content changed
+type messageEditedFrame struct {
+	Type     string    `json:"type"`
+	ID       string    `json:"id"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// messageDeletedFrame tells both users that a message was deleted for everyone
+type messageDeletedFrame struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// unreadFrame gives a user's unread message counts. Sent on connect it lists
+// every conversation with unread messages; after a read or once an incoming
+// message is stored, only that conversation.
+type unreadFrame struct {
+	Type          string               `json:"type"`
+	Conversations []unreadConversation `json:"conversations"`
+	TotalUnread   int64                `json:"total_unread"`
+}
+
+// unreadConversation is the number of unread messages from With
+type unreadConversation struct {
+	With   string `json:"with"`
+	Unread int64  `json:"unread"`
+}
+
+// ackFrame tells the sender its message, edit or deletion was accepted. ID
+// is the server's message ID, which delivery and persistence use as well.
+// Acks for deletions carry only the ID, and acks for reads the ID they named.
+type ackFrame struct {
+	Type     string     `json:"type"`
+	ClientID string     `json:"client_id,omitempty"`
+	ID       string     `json:"id"`
+	To       string     `json:"to,omitempty"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+}
+
+// errorFrame tells the sender its message was not sent and whether sending
+// it again may succeed
+type errorFrame struct {
+	Type      string `json:"type"`
+	ClientID  string `json:"client_id,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	To        string `json:"to,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+// newErrorFrame answers msg with code. An over-long client ID is not echoed.
+func newErrorFrame(msg Message, code, message string) errorFrame {
+	clientID := msg.ClientID
+	if len(clientID) > maxClientIDLength {
+		clientID = ""
+	}
+	return errorFrame{
+		Type:      frameError,
+		ClientID:  clientID,
+		Code:      code,
+		Message:   message,
+		To:        msg.To,
+		Retryable: retryableCodes[code],
+	}
+}
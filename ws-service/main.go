@@ -1,246 +1,583 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-	"sync"
-	"time"
-
-	"github.com/gorilla/websocket"
-	"github.com/segmentio/kafka-go"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-
-	auth "github.com/RishangS/auth-service/gen/proto"
-)
-
-var (
-	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
-	}
-	authClient     auth.AuthServiceClient
-	messagesWriter *kafka.Writer
-	persistWriter  *kafka.Writer
-	clients        = make(map[string]*websocket.Conn)
-	clientsMu      sync.Mutex
-)
-
-func main() {
-	// Connect to Auth Service
-	authAddr := getEnv("AUTH_SERVICE_ADDR", "localhost:50051")
-
-	authConn, err := grpc.Dial(
-		authAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		log.Fatalf("did not connect to auth service: %v", err)
-	}
-	defer authConn.Close()
-	authClient = auth.NewAuthServiceClient(authConn)
-
-	// Initialize Kafka writers
-	initKafkaWriters()
-	defer func() {
-		if err := messagesWriter.Close(); err != nil {
-			log.Printf("Error closing messages writer: %v", err)
-		}
-		if err := persistWriter.Close(); err != nil {
-			log.Printf("Error closing persist writer: %v", err)
-		}
-	}()
-
-	http.HandleFunc("/ws", handleWebSocket)
-	http.HandleFunc("/health", healthCheck)
-	// go ensureTopicExists()
-	go startKafkaConsumer()
-	log.Println("WebSocket service started on :8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-// healthCheck provides a health check endpoint
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
-}
-
-func initKafkaWriters() {
-	kafkaBrokers := getEnv("KAFKA_BROKERS", "localhost:9092")
-	messagesTopic := getEnv("KAFKA_MESSAGES_TOPIC", "messages")
-	persistTopic := getEnv("KAFKA_PERSIST_TOPIC", "persist")
-
-	// Writer for real-time messages
-	messagesWriter = kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      []string{kafkaBrokers},
-		Topic:        messagesTopic,
-		Balancer:     &kafka.Hash{},
-		BatchTimeout: 10 * time.Millisecond,
-	})
-
-	// Writer for persistence
-	persistWriter = kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      []string{kafkaBrokers},
-		Topic:        persistTopic,
-		Balancer:     &kafka.Hash{},
-		BatchTimeout: 10 * time.Millisecond,
-		RequiredAcks: -1, // Ensure message is persisted
-	})
-}
-
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	token := r.URL.Query().Get("token")
-
-	if token == "" {
-		log.Println("Rejected: No token provided")
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	// Verify token with Auth service
-	resp, err := authClient.VerifyToken(context.Background(), &auth.VerifyRequest{
-		Token: token,
-	})
-	if err != nil || !resp.Valid {
-		fmt.Println("ERROR", err.Error())
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	log.Printf("Incoming message %v", resp.Username)
-
-	username := resp.Username
-
-	// Upgrade to WebSocket connection
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("Upgrade error:", err)
-		return
-	}
-	defer conn.Close()
-
-	// Register client
-	registerClient(username, conn)
-	defer unregisterClient(username)
-
-	// Message handling loop
-	for {
-		var msg Message
-		if err := conn.ReadJSON(&msg); err != nil {
-			log.Printf("Read error for %s: %v", username, err)
-			break
-		}
-
-		// Validate message
-		if msg.To == "" || msg.Content == "" {
-			continue
-		}
-
-		// Publish to both topics
-		if err := publishMessage(username, msg); err != nil {
-			log.Printf("Error publishing message: %v", err)
-		}
-	}
-}
-
-func registerClient(username string, conn *websocket.Conn) {
-	clientsMu.Lock()
-	defer clientsMu.Unlock()
-	clients[username] = conn
-}
-
-func unregisterClient(username string) {
-	clientsMu.Lock()
-	defer clientsMu.Unlock()
-	delete(clients, username)
-}
-
-func publishMessage(sender string, msg Message) error {
-	// Common headers for both messages
-	headers := []kafka.Header{
-		{Key: "From", Value: []byte(sender)},
-		{Key: "To", Value: []byte(msg.To)},
-		{Key: "Timestamp", Value: []byte(time.Now().Format(time.RFC3339))},
-	}
-
-	// Publish to real-time topic
-	if err := messagesWriter.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:     []byte(sender),
-			Value:   []byte(msg.Content),
-			Headers: headers,
-		},
-	); err != nil {
-		return fmt.Errorf("messages topic write error: %w", err)
-	}
-
-	// Publish to persistence topic
-	if err := persistWriter.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:     []byte(sender),
-			Value:   []byte(msg.Content),
-			Headers: headers,
-		},
-	); err != nil {
-		return fmt.Errorf("persist topic write error: %w", err)
-	}
-
-	return nil
-}
-
-// Message represents the WebSocket message structure
-type Message struct {
-	To      string `json:"to"`
-	Content string `json:"content"`
-}
-
-// func ensureTopicExists() {
-// 	kafkaBrokers := getEnv("KAFKA_BROKERS", "localhost:9092")
-// 	conn, err := kafka.Dial("tcp", kafkaBrokers)
-// 	if err != nil {
-// 		log.Fatalf("Failed to connect to Kafka: %v", err)
-// 	}
-// 	defer conn.Close()
-
-// 	controller, err := conn.Controller()
-// 	if err != nil {
-// 		log.Fatalf("Failed to get controller: %v", err)
-// 	}
-
-// 	var controllerConn *kafka.Conn
-// 	controllerConn, err = kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
-// 	if err != nil {
-// 		log.Fatalf("Failed to connect to controller: %v", err)
-// 	}
-// 	defer controllerConn.Close()
-
-// 	messagesTopic := getEnv("KAFKA_MESSAGES_TOPIC", "messages")
-// 	persistTopic := getEnv("KAFKA_PERSIST_TOPIC", "persist")
-
-// 	topicConfigs := []kafka.TopicConfig{
-// 		{
-// 			Topic:             messagesTopic,
-// 			NumPartitions:     1,
-// 			ReplicationFactor: 1,
-// 		},
-// 		{
-// 			Topic:             persistTopic,
-// 			NumPartitions:     1,
-// 			ReplicationFactor: 1,
-// 		},
-// 	}
-
-// 	err = controllerConn.CreateTopics(topicConfigs...)
-// 	if err != nil {
-// 		log.Printf("Error creating topics: %v", err)
-// 	}
-// }
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/RishangS/shared/cors"
+	"github.com/RishangS/shared/events"
+	eventspb "github.com/RishangS/shared/gen/events"
+	auth "github.com/RishangS/shared/gen/proto"
+	"github.com/RishangS/shared/health"
+	"github.com/RishangS/shared/kafkaclient"
+	"github.com/RishangS/shared/logging"
+	"github.com/RishangS/shared/metrics"
+	"github.com/RishangS/shared/tlsconfig"
+	"github.com/RishangS/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	upgrader = websocket.Upgrader{
+		CheckOrigin: checkOrigin,
+	}
+	allowedOrigins *cors.Origins
+	tracer         = tracing.Tracer("github.com/RishangS/ws-service")
+	authClient     auth.AuthServiceClient
+	recipients     *recipientCache
+	messages       *events.Publisher
+	clients        = make(map[string]map[*client]struct{}) // sessions by username
+	clientsMu      sync.Mutex
+	connections    sync.WaitGroup // active WebSocket handlers
+
+	// Deadline for auth-service calls made on behalf of a connection
+	authTimeout time.Duration
+
+	// Token with which ws-service calls auth-service for itself or a user
+	serviceToken string
+
+	// Send buffer and slow consumer handling for every connection
+	clientSettings ClientConfig
+
+	// Frame size and rate limits, with buckets shared by a user's or an IP's connections
+	limits      LimitsConfig
+	userLimiter *limiterSet
+	ipLimiter   *limiterSet
+	byteLimiter *limiterSet
+)
+
+func main() {
+	// Env and CONFIG_FILE settings, validated before anything starts
+	cfg := loadConfig()
+
+	// Cancelled on SIGINT/SIGTERM to start the shutdown sequence
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Requests and publishes derive from ctx, which outlives the signal so
+	// in-flight work can drain; it is cancelled once shutdown completes
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	authTimeout = cfg.AuthTimeout
+	serviceToken = cfg.ServiceToken
+	clientSettings = cfg.Client
+	allowedOrigins = cors.NewOrigins(cfg.AllowedOrigins)
+	limits = cfg.Limits
+	userLimiter = newLimiterSet(limits.UserRate, limits.UserBurst)
+	ipLimiter = newLimiterSet(limits.IPRate, limits.IPBurst)
+	byteLimiter = newLimiterSet(limits.UserByteRate, limits.UserByteBurst)
+	go userLimiter.run(ctx)
+	go ipLimiter.run(ctx)
+	go byteLimiter.run(ctx)
+
+	// Traces are exported according to OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Init(ctx, "ws-service")
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing()
+
+	// Connect to Auth Service, over mutual TLS when a client certificate is set
+	authAddr := cfg.AuthServiceAddr
+	authCreds := insecure.NewCredentials()
+	if cfg.AuthTLS.Enabled {
+		authCerts, err := tlsconfig.Start(ctx, cfg.AuthTLS.CertFile, cfg.AuthTLS.KeyFile, cfg.AuthTLS.CAFile, cfg.TLS.ReloadInterval)
+		if err != nil {
+			logging.Fatal("Failed to load auth-service TLS certificates", "error", err)
+		}
+		authCreds = authCerts.ClientCredentials(cfg.AuthTLS.ServerName)
+	}
+
+	authConn, err := grpc.Dial(
+		authAddr,
+		grpc.WithTransportCredentials(authCreds),
+		tracing.DialOption(),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		logging.Fatal("Did not connect to auth service", "addr", authAddr, "error", err)
+	}
+	defer authConn.Close()
+	authClient = auth.NewAuthServiceClient(authConn)
+	recipients = newRecipientCache(authClient, cfg.ServiceToken, cfg.Recipients)
+
+	// Brokers, TLS and SASL settings shared by the writers and the consumer
+	kafkaClient, err := kafkaclient.New(ctx, cfg.Kafka.Kafka, "ws-service")
+	if err != nil {
+		logging.Fatal("Failed to configure Kafka client", "error", err)
+	}
+
+	// Create or validate the topic before producing to it
+	topicsCtx, cancelTopics := context.WithTimeout(ctx, cfg.Kafka.Timeout)
+	err = kafkaClient.EnsureTopics(topicsCtx, []kafkaclient.TopicSpec{
+		{Name: cfg.Kafka.MessagesTopic, Topic: cfg.Kafka.MessagesTopicConfig},
+	}, cfg.Kafka.CreateTopics)
+	cancelTopics()
+	if err != nil {
+		logging.Fatal("Kafka topics are not usable", "error", err)
+	}
+
+	// Messages are written once; delivery and persistence consume the same topic
+	messages = events.NewPublisher(kafkaClient.Writer(cfg.Kafka.MessagesTopic, cfg.Kafka.MessagesProducer), cfg.Kafka.WriteTimeout)
+	defer func() {
+		if err := messages.Close(); err != nil {
+			slog.Error("Error closing messages writer", "error", err)
+		}
+	}()
+
+	// Readiness requires Kafka and the auth service; liveness only the process
+	checker := health.NewChecker(cfg.Lifecycle.HealthCheckTimeout)
+	checker.Add("kafka", health.Kafka(kafkaClient.Dialer, kafkaClient.Brokers))
+	checker.Add("auth-service", health.GRPC(authConn, auth.AuthService_ServiceDesc.ServiceName))
+
+	http.HandleFunc("/ws", handleWebSocket)
+	checker.Register(http.DefaultServeMux)
+	metrics.Register(http.DefaultServeMux)
+
+	consumerCtx, stopConsumer := context.WithCancel(ctx)
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		startKafkaConsumer(consumerCtx, kafkaClient, cfg.Kafka)
+	}()
+
+	server := &http.Server{
+		Addr:        fmt.Sprintf(":%d", cfg.HTTPPort),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	// Serve wss:// when a certificate is configured
+	if cfg.TLS.Enabled() {
+		certs, err := tlsconfig.Start(ctx, cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CAFile, cfg.TLS.ReloadInterval)
+		if err != nil {
+			logging.Fatal("Failed to load TLS certificates", "error", err)
+		}
+		if server.TLSConfig, err = certs.ServerConfig(false); err != nil {
+			logging.Fatal("Failed to configure TLS", "error", err)
+		}
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("WebSocket service started", "addr", server.Addr, "tls", server.TLSConfig != nil)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	select {
+	case <-sigCtx.Done():
+		slog.Info("Shutdown signal received, draining connections")
+	case err := <-serveErr:
+		slog.Error("HTTP server failed, shutting down", "error", err)
+	}
+
+	// Fail readiness so no new connections are routed here while draining
+	checker.Drain()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Lifecycle.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Stop accepting new connections; hijacked WebSockets are handled below
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server shutdown", "error", err)
+	}
+
+	// Stop consuming so no offsets are committed for messages that can no longer be delivered
+	stopConsumer()
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Timed out waiting for the Kafka consumer to stop")
+	}
+
+	// Ask every client to disconnect and wait for their handlers to finish publishing
+	closeAllClients(shutdownCtx)
+	if !waitTimeout(shutdownCtx, &connections) {
+		slog.Warn("Timed out waiting for WebSocket connections to close")
+	}
+
+	// Deferred calls flush the Kafka writers and close the auth connection
+	slog.Info("WebSocket service stopped")
+}
+
+// closeAllClients sends a going-away close frame to every connected client.
+// The frames are written concurrently, outside clientsMu, each with a
+// deadline of one second or ctx's deadline if that is sooner.
+func closeAllClients(ctx context.Context) {
+	clientsMu.Lock()
+	var sessions []*client
+	for _, set := range clients {
+		for c := range set {
+			sessions = append(sessions, c)
+		}
+	}
+	clientsMu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	var wg sync.WaitGroup
+	for _, c := range sessions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.conn.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+				writeErrors.Inc()
+				slog.Warn("Error sending close frame", logging.KeyUsername, c.username, "error", err)
+				c.conn.Close()
+			}
+		}()
+	}
+	waitTimeout(ctx, &wg)
+}
+
+// waitTimeout waits for wg and reports false if ctx expired first
+func waitTimeout(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	// Every log line for this connection carries its IDs
+	requestID := r.Header.Get(logging.RequestIDHeader)
+	if requestID == "" {
+		requestID = logging.NewID()
+	}
+	clientIP := remoteIP(r)
+	connCtx := logging.With(r.Context(),
+		slog.String(logging.KeyConnID, logging.NewID()),
+		slog.String(logging.KeyRequestID, requestID),
+		slog.String(logging.KeyClientIP, clientIP),
+	)
+
+	// Refuse cross-site pages before the token is checked, so a stolen
+	// token cannot be used or probed from another website
+	if !checkOrigin(r) {
+		slog.InfoContext(connCtx, "Rejected: origin not allowed", "origin", r.Header.Get("Origin"))
+		rejectedUpgrades.WithLabelValues("origin").Inc()
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if token == "" {
+		slog.InfoContext(connCtx, "Rejected: no token provided")
+		rejectedUpgrades.WithLabelValues("no_token").Inc()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Verify token with Auth service
+	ctx, span := tracer.Start(connCtx, "ws.handshake", trace.WithSpanKind(trace.SpanKindServer))
+	verifyCtx, cancel := context.WithTimeout(ctx, authTimeout)
+	resp, err := authClient.VerifyToken(verifyCtx, &auth.VerifyRequest{
+		Token: token,
+	})
+	cancel()
+	if err != nil || !resp.Valid {
+		slog.InfoContext(ctx, "Rejected: token verification failed", "error", err)
+		rejectedUpgrades.WithLabelValues("invalid_token").Inc()
+		span.SetStatus(codes.Error, "token verification failed")
+		span.End()
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	username, userID := resp.Username, resp.UserId
+	connCtx = logging.With(connCtx, slog.String(logging.KeyUsername, username))
+
+	connections.Add(1)
+	defer connections.Done()
+
+	// Upgrade to WebSocket connection
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.WarnContext(connCtx, "Upgrade error", "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "upgrade failed")
+		span.End()
+		return
+	}
+	defer conn.Close()
+	// Larger frames fail the read and close the connection with 1009
+	conn.SetReadLimit(limits.MaxFrameBytes)
+	span.SetAttributes(attribute.String("enduser.id", username))
+	handshake := trace.LinkFromContext(ctx)
+	span.End()
+	slog.InfoContext(connCtx, "Client connected")
+
+	activeConnections.Inc()
+	defer activeConnections.Dec()
+
+	// Register client; its write pump owns all data frames to the connection
+	self := newClient(connCtx, username, conn, clientSettings)
+	registerClient(self)
+	defer unregisterClient(self)
+	defer self.close()
+	self.startHeartbeat()
+	go self.writePump()
+	sendUnreadCounts(connCtx, userID, self)
+
+	// Message handling loop
+	limiter := newFrameLimiter(username, clientIP)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				rejectedFrames.WithLabelValues(rejectFrameTooLarge).Inc()
+				slog.WarnContext(connCtx, "Closing connection after oversized frame", "limit", limits.MaxFrameBytes)
+			} else if !self.reapIfTimedOut(err) {
+				slog.InfoContext(connCtx, "Client disconnected", "reason", err)
+			}
+			break
+		}
+		framesReceived.Inc()
+
+		// Frames are charged as they arrive, before they are decoded. A
+		// client that keeps sending while limited is disconnected; otherwise
+		// the frame is decoded only to echo its client_id in the error.
+		var msg Message
+		if !limiter.charge(len(data)) {
+			rejectedFrames.WithLabelValues(rejectRateLimited).Inc()
+			if limiter.abusive() {
+				rateLimitedDisconnects.Inc()
+				slog.WarnContext(connCtx, "Closing connection after sustained rate limiting", "frames", limits.MaxRateLimited)
+				closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded")
+				conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+				break
+			}
+			json.Unmarshal(data, &msg)
+			self.enqueue(newErrorFrame(msg, codeRateLimited, "rate limited"))
+			continue
+		}
+		decodeErr := json.Unmarshal(data, &msg)
+		if len(msg.Content) > limits.MaxContentBytes {
+			rejectedFrames.WithLabelValues(rejectContentTooLarge).Inc()
+			self.enqueue(newErrorFrame(msg, codeMessageTooLarge, "message too large"))
+			continue
+		}
+		if problem := validateMessage(msg, decodeErr); problem != "" {
+			rejectedFrames.WithLabelValues(rejectInvalid).Inc()
+			self.enqueue(newErrorFrame(msg, codeInvalidMessage, problem))
+			continue
+		}
+
+		// Each frame starts its own trace, linked to the connection's handshake
+		frameCtx, frameSpan := tracer.Start(connCtx, "ws.receive",
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithLinks(handshake),
+			trace.WithAttributes(
+				attribute.String("chat.from", username),
+				attribute.String("chat.to", msg.To),
+			),
+		)
+
+		// Answer with an ack, or an error frame if the message, edit or
+		// deletion was refused or could not be carried out
+		var reply any
+		switch msg.Type {
+		case frameEdit:
+			reply, err = editMessage(frameCtx, userID, msg)
+		case frameDelete:
+			reply, err = deleteMessage(frameCtx, userID, msg)
+		case frameRead:
+			reply, err = markRead(frameCtx, userID, msg)
+		default:
+			reply, err = sendMessage(frameCtx, userID, username, msg)
+		}
+		if err != nil {
+			frameSpan.RecordError(err)
+			frameSpan.SetStatus(codes.Error, "message not sent")
+		}
+		self.enqueue(reply)
+		frameSpan.End()
+	}
+}
+
+// validateMessage returns why msg cannot be sent, or "" if it can
+func validateMessage(msg Message, decodeErr error) string {
+	switch {
+	case decodeErr != nil:
+		return "frame is not a valid message"
+	case len(msg.ClientID) > maxClientIDLength:
+		return fmt.Sprintf("client_id must be at most %d characters", maxClientIDLength)
+	}
+
+	switch msg.Type {
+	case "", frameMessage:
+		switch {
+		case msg.To == "":
+			return "to is required"
+		case msg.Content == "":
+			return "content is required"
+		case msg.ContentType != "" && !slices.Contains(limits.ContentTypes, msg.ContentType):
+			return "content_type is not allowed"
+		case len(msg.Metadata) > limits.MaxMetadataKeys:
+			return fmt.Sprintf("metadata must have at most %d keys", limits.MaxMetadataKeys)
+		case metadataBytes(msg.Metadata) > limits.MaxMetadataBytes:
+			return fmt.Sprintf("metadata must be at most %d bytes", limits.MaxMetadataBytes)
+		}
+	case frameEdit:
+		switch {
+		case msg.ID == "":
+			return "id is required"
+		case msg.Content == "":
+			return "content is required"
+		}
+	case frameDelete:
+		if msg.ID == "" {
+			return "id is required"
+		}
+	case frameRead:
+		if msg.With == "" {
+			return "with is required"
+		}
+	default:
+		return "unknown frame type"
+	}
+	return ""
+}
+
+// metadataBytes is the total length of metadata's keys and values
+func metadataBytes(metadata map[string]string) int {
+	n := 0
+	for key, value := range metadata {
+		n += len(key) + len(value)
+	}
+	return n
+}
+
+// checkOrigin allows requests without an Origin, from the service's own
+// host, or from an origin in WS_ALLOWED_ORIGINS
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || cors.SameHost(origin, r) || allowedOrigins.Allowed(origin)
+}
+
+// remoteIP returns the client's address, taken from the hop appended by a
+// trusted proxy when WS_TRUST_FORWARDED_FOR is set
+func remoteIP(r *http.Request) string {
+	if limits.TrustForwardedFor {
+		if hops := strings.Split(r.Header.Get("X-Forwarded-For"), ","); hops[len(hops)-1] != "" {
+			return strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Recipient check answers that refuse a message, with the error code and
+// rejectedFrames reason for each
+var refusals = []struct {
+	err          error
+	code, reason string
+}{
+	{errUnknownRecipient, codeUnknownRecipient, rejectBadRecipient},
+	{errInactiveRecipient, codeInactiveRecipient, rejectBadRecipient},
+	{errRecipientBlocked, codeRecipientBlocked, rejectNotPermitted},
+	{errNotAccepted, codeNotAccepted, rejectNotPermitted},
+}
+
+// sendMessage checks that the recipient exists, is active and accepts
+// messages from the sender, then publishes msg once for delivery and
+// persistence. It returns the frame answering the sender: an ack, or an
+// error frame together with the error.
+func sendMessage(ctx context.Context, senderID int64, sender string, msg Message) (any, error) {
+	lookupCtx, cancel := context.WithTimeout(ctx, authTimeout)
+	recipientID, err := recipients.check(lookupCtx, msg.To)
+	if err == nil {
+		err = recipients.checkPermission(lookupCtx, sender, msg.To)
+	}
+	cancel()
+	if err != nil {
+		for _, r := range refusals {
+			if errors.Is(err, r.err) {
+				rejectedFrames.WithLabelValues(r.reason).Inc()
+				slog.InfoContext(ctx, "Rejected message", "to", msg.To, "reason", err)
+				return newErrorFrame(msg, r.code, err.Error()), err
+			}
+		}
+		slog.ErrorContext(ctx, "Error checking recipient", "error", err)
+		return newErrorFrame(msg, codeUnavailable, "recipient could not be checked"), err
+	}
+
+	event, err := publishMessage(ctx, senderID, sender, recipientID, msg)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error publishing message", "error", err)
+		return newErrorFrame(msg, codePublishFailed, "message not sent"), err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("chat.message_id", event.EventId))
+	sentAt := event.OccurredAt.AsTime()
+	return ackFrame{
+		Type:     frameAck,
+		ClientID: msg.ClientID,
+		ID:       event.EventId,
+		To:       msg.To,
+		SentAt:   &sentAt,
+	}, nil
+}
+
+// publishMessage writes msg to the messages topic and returns the event
+// recorded for it
+func publishMessage(ctx context.Context, senderID int64, sender string, recipientID int64, msg Message) (*eventspb.ChatEvent, error) {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = events.ContentTypeText
+	}
+
+	// A single record is both delivered and persisted, so the message either
+	// reaches both consumers or, if this write fails, neither
+	event := events.NewMessageSent(&eventspb.MessageSent{
+		SenderId:    senderID,
+		Sender:      sender,
+		RecipientId: recipientID,
+		Recipient:   msg.To,
+		ContentType: contentType,
+		Content:     msg.Content,
+		Metadata:    msg.Metadata,
+	})
+	if err := messages.Publish(ctx, sender, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
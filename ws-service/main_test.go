@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestValidateMessageContentTypeAndMetadata checks the content type
+// allowlist and the metadata limits
+func TestValidateMessageContentTypeAndMetadata(t *testing.T) {
+	limits = LimitsConfig{
+		ContentTypes:     []string{"text/plain", "text/markdown"},
+		MaxMetadataKeys:  2,
+		MaxMetadataBytes: 16,
+	}
+	t.Cleanup(func() { limits = LimitsConfig{} })
+
+	for _, tc := range []struct {
+		name string
+		msg  Message
+		ok   bool
+	}{
+		{name: "default content type", msg: Message{To: "bob", Content: "hi"}, ok: true},
+		{name: "allowed content type", msg: Message{To: "bob", Content: "hi", ContentType: "text/markdown"}, ok: true},
+		{name: "unknown content type", msg: Message{To: "bob", Content: "hi", ContentType: "text/html"}},
+		{name: "metadata within limits", msg: Message{To: "bob", Content: "hi", Metadata: map[string]string{"a": "1", "b": "2"}}, ok: true},
+		{name: "too many metadata keys", msg: Message{To: "bob", Content: "hi", Metadata: map[string]string{"a": "1", "b": "2", "c": "3"}}},
+		{name: "metadata too large", msg: Message{To: "bob", Content: "hi", Metadata: map[string]string{"a": strings.Repeat("x", 16)}}},
+	} {
+		problem := validateMessage(tc.msg, nil)
+		if (problem == "") != tc.ok {
+			t.Errorf("%s: got problem %q, want ok %v", tc.name, problem, tc.ok)
+		}
+	}
+}
+
+// TestCloseAllClientsSendsGoingAway checks that every session of every user
+// receives a going-away close frame
+func TestCloseAllClientsSendsGoingAway(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := newClient(context.Background(), r.URL.Query().Get("user"), conn, ClientConfig{SendBuffer: 1})
+		registerClient(c)
+		defer unregisterClient(c)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	var conns []*websocket.Conn
+	for _, user := range []string{"alice", "bob", "bob"} {
+		conn, _, err := websocket.DefaultDialer.Dial(url+"?user="+user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for deadline := time.Now().Add(time.Second); len(sessionsOf("alice"))+len(sessionsOf("bob")) < len(conns); {
+		if time.Now().After(deadline) {
+			t.Fatal("clients were not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	closeAllClients(ctx)
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("connection %d: got %v, want a going-away close", i, err)
+		}
+	}
+}
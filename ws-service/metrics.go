@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons an incoming frame is rejected, recorded in rejectedFrames
+const (
+	rejectFrameTooLarge   = "frame_too_large"
+	rejectContentTooLarge = "content_too_large"
+	rejectRateLimited     = "rate_limited"
+	rejectBadRecipient    = "bad_recipient"
+	rejectNotPermitted    = "not_permitted"
+	rejectInvalid         = "invalid"
+)
+
+// WebSocket metrics, served on /metrics alongside the shared Kafka metrics
+var (
+	activeConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ws_active_connections",
+		Help: "Number of currently open WebSocket connections.",
+	})
+
+	framesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ws_frames_received_total",
+		Help: "Chat frames read from clients.",
+	})
+
+	framesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ws_frames_sent_total",
+		Help: "Chat frames written to clients.",
+	})
+
+	writeErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ws_write_errors_total",
+		Help: "Failed writes to client connections.",
+	})
+
+	slowConsumers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_slow_consumer_total",
+		Help: "Frames that found a client's send buffer full, by the action taken (drop or disconnect).",
+	}, []string{"action"})
+
+	reapedConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_reaped_connections_total",
+		Help: "Connections closed by the server for missing heartbeats, by reason.",
+	}, []string{"reason"})
+
+	rejectedFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_rejected_frames_total",
+		Help: "Frames refused as invalid, too large, over a rate limit, to an unknown or inactive recipient, between users who may not message each other, or as edits and deletions auth-service refused, by reason.",
+	}, []string{"reason"})
+
+	rateLimitedDisconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ws_rate_limited_disconnects_total",
+		Help: "Connections closed for sending WS_MAX_RATE_LIMITED rate-limited frames in a row.",
+	})
+
+	recipientLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_recipient_lookups_total",
+		Help: "Recipient checks, by whether the cache answered (hit) or auth-service was asked (miss).",
+	}, []string{"result"})
+
+	rejectedUpgrades = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ws_rejected_upgrades_total",
+		Help: "Connection attempts refused before the upgrade, by reason (origin, no_token or invalid_token).",
+	}, []string{"reason"})
+)
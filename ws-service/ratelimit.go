@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long an untouched bucket is kept, at minimum
+const limiterIdleTTL = 10 * time.Minute
+
+// limiterSet holds a token bucket per key, such as a username or client IP.
+// Buckets outlive connections, so reconnecting does not refill them; a
+// bucket left untouched for idleTTL is dropped by sweep.
+type limiterSet struct {
+	limit   rate.Limit
+	burst   int
+	idleTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLimiterSet(perSecond float64, burst int) *limiterSet {
+	// Keep buckets at least until they would have refilled completely
+	idleTTL := max(limiterIdleTTL, time.Duration(float64(burst)/perSecond*float64(time.Second)))
+	return &limiterSet{
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+		idleTTL: idleTTL,
+		entries: make(map[string]*limiterEntry),
+	}
+}
+
+// allow takes a token from key's bucket and reports whether one was available
+func (s *limiterSet) allow(key string) bool {
+	return s.allowN(key, 1)
+}
+
+// allowN takes n tokens from key's bucket and reports whether they were
+// available
+func (s *limiterSet) allowN(key string, n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.entries[key]
+	if !ok {
+		e = &limiterEntry{limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.entries[key] = e
+	}
+	e.lastSeen = now
+	return e.limiter.AllowN(now, n)
+}
+
+// sweep drops buckets idle for longer than idleTTL. A dropped bucket had
+// refilled long ago, so forgetting it changes nothing for its key.
+func (s *limiterSet) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := time.Now().Add(-s.idleTTL)
+	for key, e := range s.entries {
+		if e.lastSeen.Before(cutoff) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// run sweeps the set every idleTTL until ctx is cancelled
+func (s *limiterSet) run(ctx context.Context) {
+	ticker := time.NewTicker(s.idleTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+// frameLimiter charges one connection's frames to the shared buckets: one
+// token from the user's and the IP's message buckets and the frame's size
+// from the user's byte bucket. It counts consecutive rejected frames, so a
+// client that keeps sending while limited can be disconnected.
+type frameLimiter struct {
+	users, ips, bytes *limiterSet
+	username          string
+	clientIP          string
+	maxRejected       int
+	rejected          int
+}
+
+func newFrameLimiter(username, clientIP string) *frameLimiter {
+	return &frameLimiter{
+		users:       userLimiter,
+		ips:         ipLimiter,
+		bytes:       byteLimiter,
+		username:    username,
+		clientIP:    clientIP,
+		maxRejected: limits.MaxRateLimited,
+	}
+}
+
+// charge charges a frame of size bytes and reports whether every bucket had
+// room. All buckets are charged before any is checked, so a frame one bucket
+// rejects still costs the others.
+func (f *frameLimiter) charge(size int) bool {
+	userAllowed := f.users.allow(f.username)
+	ipAllowed := f.ips.allow(f.clientIP)
+	bytesAllowed := f.bytes.allowN(f.username, size)
+	if userAllowed && ipAllowed && bytesAllowed {
+		f.rejected = 0
+		return true
+	}
+	f.rejected++
+	return false
+}
+
+// abusive reports whether the last maxRejected frames were all rejected
+func (f *frameLimiter) abusive() bool {
+	return f.rejected >= f.maxRejected
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLimiterSetBurst checks that a key may spend its burst at once and no
+// more, without affecting other keys
+func TestLimiterSetBurst(t *testing.T) {
+	set := newLimiterSet(1, 3)
+	for i := 0; i < 3; i++ {
+		if !set.allow("alice") {
+			t.Fatalf("frame %d within the burst was refused", i+1)
+		}
+	}
+	if set.allow("alice") {
+		t.Error("frame beyond the burst was allowed")
+	}
+	if !set.allow("bob") {
+		t.Error("another key's frame was refused")
+	}
+}
+
+// TestLimiterSetRefill checks that tokens return at the configured rate
+func TestLimiterSetRefill(t *testing.T) {
+	set := newLimiterSet(100, 1)
+	if !set.allow("alice") {
+		t.Fatal("first frame was refused")
+	}
+	if set.allow("alice") {
+		t.Fatal("frame with an empty bucket was allowed")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !set.allow("alice") {
+		t.Error("frame after the bucket refilled was refused")
+	}
+}
+
+// TestLimiterSetAllowN checks that byte buckets are charged by size
+func TestLimiterSetAllowN(t *testing.T) {
+	set := newLimiterSet(1, 100)
+	if !set.allowN("alice", 60) {
+		t.Fatal("60 bytes of a 100 byte burst were refused")
+	}
+	if set.allowN("alice", 60) {
+		t.Error("60 bytes with 40 left were allowed")
+	}
+	if !set.allowN("alice", 40) {
+		t.Error("40 bytes with 40 left were refused")
+	}
+}
+
+// TestFrameLimiterClosesOnSustainedAbuse checks that only consecutive
+// rejections count towards disconnecting, and that every bucket is charged
+// even when another rejects the frame
+func TestFrameLimiterClosesOnSustainedAbuse(t *testing.T) {
+	f := &frameLimiter{
+		users:       newLimiterSet(0.001, 2),
+		ips:         newLimiterSet(0.001, 10),
+		bytes:       newLimiterSet(0.001, 1000),
+		username:    "alice",
+		clientIP:    "192.0.2.1",
+		maxRejected: 3,
+	}
+
+	// An oversized frame is rejected by the byte bucket, then a small one
+	// passes and resets the count
+	if f.charge(2000) {
+		t.Fatal("frame larger than the byte burst was allowed")
+	}
+	if !f.charge(10) {
+		t.Fatal("frame within every bucket was refused")
+	}
+	if f.abusive() {
+		t.Fatal("abusive after an allowed frame")
+	}
+
+	// The user's message bucket is now empty
+	for i := 1; i <= 3; i++ {
+		if f.charge(10) {
+			t.Fatalf("frame %d with an empty bucket was allowed", i)
+		}
+		if got, want := f.abusive(), i == 3; got != want {
+			t.Fatalf("after %d rejected frames abusive is %v, want %v", i, got, want)
+		}
+	}
+
+	// Five frames were charged to the IP bucket, including the rejected ones
+	for i := 0; i < 5; i++ {
+		if !f.ips.allow(f.clientIP) {
+			t.Fatalf("IP bucket has %d tokens left, want 5", i)
+		}
+	}
+	if f.ips.allow(f.clientIP) {
+		t.Error("IP bucket was not charged for rejected frames")
+	}
+}
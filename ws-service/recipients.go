@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// errUnknownRecipient is returned for usernames auth-service does not know
+	errUnknownRecipient = errors.New("unknown recipient")
+	// errInactiveRecipient is returned for deactivated accounts
+	errInactiveRecipient = errors.New("recipient is not active")
+	// errRecipientBlocked is returned when the sender has blocked the recipient
+	errRecipientBlocked = errors.New("you have blocked this user")
+	// errNotAccepted is returned when the recipient has blocked the sender or
+	// accepts messages only from contacts
+	errNotAccepted = errors.New("recipient does not accept messages from you")
+)
+
+// recipientCache remembers auth-service lookups so that a conversation does
+// not cost two RPCs per message. Unknown users are cached for a shorter time,
+// so a user who signs up is reachable soon after, and permissions for a
+// shorter time still, so blocks apply quickly.
+type recipientCache struct {
+	client        auth.AuthServiceClient
+	token         string
+	ttl           time.Duration
+	negativeTTL   time.Duration
+	permissionTTL time.Duration
+	size          int
+
+	mu      sync.Mutex
+	entries map[string]recipientEntry
+}
+
+type recipientEntry struct {
+	userID  int64 // the recipient's ID when a user lookup found them
+	err     error // nil or one of the errors above
+	expires time.Time
+}
+
+func newRecipientCache(client auth.AuthServiceClient, token string, cfg RecipientsConfig) *recipientCache {
+	return &recipientCache{
+		client:        client,
+		token:         token,
+		ttl:           cfg.CacheTTL,
+		negativeTTL:   cfg.NegativeTTL,
+		permissionTTL: cfg.PermissionTTL,
+		size:          cfg.CacheSize,
+		entries:       make(map[string]recipientEntry),
+	}
+}
+
+// check returns username's user ID if they can receive messages,
+// errUnknownRecipient or errInactiveRecipient if not, or the lookup error if
+// auth-service could not answer. Lookup errors are not cached.
+func (c *recipientCache) check(ctx context.Context, username string) (int64, error) {
+	e, err := c.cached(username, func() (recipientEntry, time.Duration, error) {
+		resp, err := c.client.LookupUser(withToken(ctx, c.token), &auth.LookupUserRequest{Username: username})
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return recipientEntry{err: errUnknownRecipient}, c.negativeTTL, nil
+		case err != nil:
+			return recipientEntry{}, 0, fmt.Errorf("error looking up recipient: %w", err)
+		case !resp.Active:
+			return recipientEntry{err: errInactiveRecipient}, c.ttl, nil
+		}
+		return recipientEntry{userID: resp.UserId}, c.ttl, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return e.userID, e.err
+}
+
+// checkPermission returns nil if sender may message recipient,
+// errRecipientBlocked or errNotAccepted if not, or the lookup error if
+// auth-service could not answer
+func (c *recipientCache) checkPermission(ctx context.Context, sender, recipient string) error {
+	e, err := c.cached(sender+"\x00"+recipient, func() (recipientEntry, time.Duration, error) {
+		resp, err := c.client.CheckMessaging(withToken(ctx, c.token), &auth.CheckMessagingRequest{Sender: sender, Recipient: recipient})
+		switch {
+		case status.Code(err) == codes.NotFound:
+			return recipientEntry{err: errUnknownRecipient}, c.negativeTTL, nil
+		case err != nil:
+			return recipientEntry{}, 0, fmt.Errorf("error checking messaging permission: %w", err)
+		case resp.Decision == auth.MessagingDecision_MESSAGING_RECIPIENT_BLOCKED:
+			return recipientEntry{err: errRecipientBlocked}, c.permissionTTL, nil
+		case resp.Decision != auth.MessagingDecision_MESSAGING_ALLOWED:
+			return recipientEntry{err: errNotAccepted}, c.permissionTTL, nil
+		}
+		return recipientEntry{}, c.permissionTTL, nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+// cached returns the entry stored under key, or calls lookup and stores its
+// answer for the TTL it returns. Lookup errors are returned and not stored.
+func (c *recipientCache) cached(key string, lookup func() (answer recipientEntry, ttl time.Duration, err error)) (recipientEntry, error) {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		recipientLookups.WithLabelValues("hit").Inc()
+		return e, nil
+	}
+	recipientLookups.WithLabelValues("miss").Inc()
+
+	answer, ttl, err := lookup()
+	if err != nil {
+		return recipientEntry{}, err
+	}
+	answer.expires = now.Add(ttl)
+	c.store(key, answer)
+	return answer, nil
+}
+
+// store adds an entry, first making room by dropping expired entries and,
+// if the cache is still full, an arbitrary one
+func (c *recipientCache) store(key string, e recipientEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		now := time.Now()
+		for name, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, name)
+			}
+		}
+		for name := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, name)
+		}
+	}
+	c.entries[key] = e
+}
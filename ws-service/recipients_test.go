@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuth answers LookupUser and CheckMessaging from maps and counts the
+// calls; every other method panics through the nil embedded client
+type fakeAuth struct {
+	auth.AuthServiceClient
+	users     map[string]*auth.LookupUserResponse
+	decisions map[string]auth.MessagingDecision
+	err       error
+	calls     int
+	token     string
+}
+
+func (f *fakeAuth) LookupUser(ctx context.Context, req *auth.LookupUserRequest, _ ...grpc.CallOption) (*auth.LookupUserResponse, error) {
+	f.calls++
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		f.token = values[0]
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp, ok := f.users[req.Username]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return resp, nil
+}
+
+func (f *fakeAuth) CheckMessaging(_ context.Context, req *auth.CheckMessagingRequest, _ ...grpc.CallOption) (*auth.CheckMessagingResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &auth.CheckMessagingResponse{Decision: f.decisions[req.Sender+">"+req.Recipient]}, nil
+}
+
+func newTestCache(client *fakeAuth) *recipientCache {
+	return newRecipientCache(client, "service-token", RecipientsConfig{
+		CacheTTL:      time.Hour,
+		NegativeTTL:   20 * time.Millisecond,
+		PermissionTTL: 20 * time.Millisecond,
+		CacheSize:     10,
+	})
+}
+
+// TestRecipientCacheCachesAnswers checks that found and inactive users are
+// cached for the full TTL, with the service token sent on lookups
+func TestRecipientCacheCachesAnswers(t *testing.T) {
+	client := &fakeAuth{users: map[string]*auth.LookupUserResponse{
+		"bob":   {UserId: 2, Active: true},
+		"carol": {UserId: 3},
+	}}
+	cache := newTestCache(client)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if id, err := cache.check(ctx, "bob"); id != 2 || err != nil {
+			t.Fatalf("bob: got %d, %v, want 2", id, err)
+		}
+		if _, err := cache.check(ctx, "carol"); !errors.Is(err, errInactiveRecipient) {
+			t.Fatalf("carol: got %v, want errInactiveRecipient", err)
+		}
+	}
+	if client.calls != 2 {
+		t.Errorf("made %d lookups for two users, want 2", client.calls)
+	}
+	if client.token != "Bearer service-token" {
+		t.Errorf("sent authorization %q, want the service token", client.token)
+	}
+}
+
+// TestRecipientCacheNegativeTTL checks that unknown users are cached only
+// for the negative TTL, so a new sign-up becomes reachable
+func TestRecipientCacheNegativeTTL(t *testing.T) {
+	client := &fakeAuth{users: map[string]*auth.LookupUserResponse{}}
+	cache := newTestCache(client)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.check(ctx, "dave"); !errors.Is(err, errUnknownRecipient) {
+			t.Fatalf("got %v, want errUnknownRecipient", err)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("made %d lookups within the negative TTL, want 1", client.calls)
+	}
+
+	client.users["dave"] = &auth.LookupUserResponse{UserId: 4, Active: true}
+	time.Sleep(30 * time.Millisecond)
+	if id, err := cache.check(ctx, "dave"); id != 4 || err != nil {
+		t.Errorf("after the negative TTL got %d, %v, want 4", id, err)
+	}
+}
+
+// TestRecipientCacheSkipsErrors checks that a failed lookup is retried on
+// the next message rather than cached
+func TestRecipientCacheSkipsErrors(t *testing.T) {
+	client := &fakeAuth{
+		users: map[string]*auth.LookupUserResponse{"bob": {UserId: 2, Active: true}},
+		err:   status.Error(codes.Unavailable, "connection refused"),
+	}
+	cache := newTestCache(client)
+	ctx := context.Background()
+
+	if _, err := cache.check(ctx, "bob"); err == nil || errors.Is(err, errUnknownRecipient) {
+		t.Fatalf("got %v, want the lookup error", err)
+	}
+	client.err = nil
+	if id, err := cache.check(ctx, "bob"); id != 2 || err != nil {
+		t.Errorf("after recovery got %d, %v, want 2", id, err)
+	}
+}
+
+// TestRecipientCachePermissions checks the permission answers and that they
+// expire after the permission TTL, so a new block applies
+func TestRecipientCachePermissions(t *testing.T) {
+	client := &fakeAuth{decisions: map[string]auth.MessagingDecision{
+		"alice>bob":   auth.MessagingDecision_MESSAGING_ALLOWED,
+		"alice>carol": auth.MessagingDecision_MESSAGING_RECIPIENT_BLOCKED,
+		"alice>dave":  auth.MessagingDecision_MESSAGING_NOT_ACCEPTED,
+	}}
+	cache := newTestCache(client)
+	ctx := context.Background()
+
+	for recipient, want := range map[string]error{"bob": nil, "carol": errRecipientBlocked, "dave": errNotAccepted} {
+		if err := cache.checkPermission(ctx, "alice", recipient); !errors.Is(err, want) {
+			t.Errorf("%s: got %v, want %v", recipient, err, want)
+		}
+	}
+
+	client.decisions["alice>bob"] = auth.MessagingDecision_MESSAGING_NOT_ACCEPTED
+	if err := cache.checkPermission(ctx, "alice", "bob"); err != nil {
+		t.Errorf("within the TTL got %v, want the cached answer", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := cache.checkPermission(ctx, "alice", "bob"); !errors.Is(err, errNotAccepted) {
+		t.Errorf("after the TTL got %v, want errNotAccepted", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	auth "github.com/RishangS/shared/gen/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// markRead asks auth-service to mark the conversation with msg.With read up
+// to message msg.ID, or entirely without an ID, like editMessage. The new
+// counts arrive separately as an unread frame.
+func markRead(ctx context.Context, userID int64, msg Message) (any, error) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("chat.with", msg.With),
+		attribute.String("chat.message_id", msg.ID),
+	)
+	callCtx, cancel := context.WithTimeout(asUser(ctx, userID), authTimeout)
+	defer cancel()
+
+	_, err := authClient.MarkConversationRead(callCtx, &auth.MarkConversationReadRequest{
+		Username:  msg.With,
+		MessageId: msg.ID,
+	})
+	if err != nil {
+		return changeError(ctx, msg, err), err
+	}
+	return ackFrame{Type: frameAck, ClientID: msg.ClientID, ID: msg.ID}, nil
+}
+
+// sendUnreadCounts queues c's unread counts for every conversation. Without
+// them the client simply starts from its own counts, so errors are only logged.
+func sendUnreadCounts(ctx context.Context, userID int64, c *client) {
+	callCtx, cancel := context.WithTimeout(asUser(ctx, userID), authTimeout)
+	defer cancel()
+
+	resp, err := authClient.GetUnreadCounts(callCtx, &auth.GetUnreadCountsRequest{})
+	if err != nil {
+		slog.WarnContext(ctx, "Error getting unread counts", "error", err)
+		return
+	}
+
+	frame := unreadFrame{
+		Type:          frameUnread,
+		Conversations: make([]unreadConversation, len(resp.Conversations)),
+		TotalUnread:   resp.TotalUnread,
+	}
+	for i, conv := range resp.Conversations {
+		frame.Conversations[i] = unreadConversation{With: conv.Username, Unread: conv.UnreadCount}
+	}
+	c.enqueue(frame)
+}